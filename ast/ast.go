@@ -0,0 +1,98 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Package ast declares the types used to represent the parse tree of mustache
+// templates. It allows tools such as linters, formatters and analyzers to
+// inspect templates parsed by the mustache package.
+package ast
+
+import "fmt"
+
+// Pos describes a position in the source of a template. Lines and columns are
+// counted from 1.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+// IsValid reports whether the position is valid.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position in the form "line:col".
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Node is the interface implemented by all nodes of the parse tree.
+type Node interface {
+	// Pos returns the position of the first character of the node.
+	Pos() Pos
+}
+
+// Tree is the root of the parse tree of a template.
+type Tree struct {
+	Name  string // name of the template
+	Nodes []Node // top level nodes of the template
+}
+
+// Text represents a part of the template made up solely of text.
+type Text struct {
+	Start Pos
+	Text  string
+}
+
+// Var represents a variable tag such as {{name}}, {{{name}}} or {{&name}}.
+type Var struct {
+	Start   Pos
+	Name    string
+	Escaped bool     // false for {{{name}}} and {{&name}}
+	Triple  bool     // true for {{{name}}}
+	Filters []Filter // filters such as {{name | upper}}, in the order they apply
+}
+
+// Filter represents a filter applied to the value of a variable, such as
+// truncate 20 in {{name | truncate 20}}. Filters are an extension to mustache.
+type Filter struct {
+	Name string
+	Args []string // arguments, with quoted arguments unquoted
+}
+
+// Section represents a section such as {{#name}}...{{/name}} or an inverted
+// section such as {{^name}}...{{/name}}.
+type Section struct {
+	Start    Pos
+	End      Pos // position of the closing tag
+	Name     string
+	Inverted bool
+	Nodes    []Node
+}
+
+// Partial represents a partial tag such as {{>name}}.
+type Partial struct {
+	Start Pos
+	Name  string
+}
+
+// Comment represents a comment tag such as {{! text }}.
+type Comment struct {
+	Start Pos
+	Text  string
+}
+
+// Delim represents a set delimiter tag such as {{=<% %>=}}.
+type Delim struct {
+	Start Pos
+	Left  string // the new left delimiter, such as <%
+	Right string // the new right delimiter, such as %>
+}
+
+// Pos implementations of the Node interface.
+
+func (t *Tree) Pos() Pos    { return Pos{1, 1} }
+func (n *Text) Pos() Pos    { return n.Start }
+func (n *Var) Pos() Pos     { return n.Start }
+func (n *Section) Pos() Pos { return n.Start }
+func (n *Partial) Pos() Pos { return n.Start }
+func (n *Comment) Pos() Pos { return n.Start }
+func (n *Delim) Pos() Pos   { return n.Start }
@@ -0,0 +1,52 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	tree := &Tree{Nodes: []Node{
+		&Text{Pos{1, 1}, "Hello "},
+		&Section{Start: Pos{1, 7}, End: Pos{1, 30}, Name: "people", Nodes: []Node{
+			&Var{Start: Pos{1, 18}, Name: "name", Escaped: true},
+			&Partial{Pos{1, 26}, "sep"},
+		}},
+		&Comment{Pos{1, 40}, "done"},
+	}}
+	var visited []string
+	Inspect(tree, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		visited = append(visited, fmt.Sprintf("%T@%s", n, n.Pos()))
+		return true
+	})
+	expected := []string{
+		"*ast.Tree@1:1",
+		"*ast.Text@1:1",
+		"*ast.Section@1:7",
+		"*ast.Var@1:18",
+		"*ast.Partial@1:26",
+		"*ast.Comment@1:40",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("unexpected nodes visited %q, expected %q", visited, expected)
+	}
+}
+
+func TestInspectSkip(t *testing.T) {
+	tree := &Tree{Nodes: []Node{
+		&Section{Name: "outer", Nodes: []Node{&Var{Name: "inner"}}},
+	}}
+	Inspect(tree, func(n Node) bool {
+		if v, ok := n.(*Var); ok {
+			t.Errorf("unexpected visit of %q", v.Name)
+		}
+		_, ok := n.(*Section)
+		return !ok
+	})
+}
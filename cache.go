@@ -0,0 +1,219 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+// The Cache type loads templates from the .mustache files of a directory and
+// reloads them when they change. Templates are named after their path relative
+// to the directory, without the extension, such as "pages/home" for the file
+// pages/home.mustache. Partials are looked up the same way, so {{>header}} in
+// any template includes header.mustache. Names which would leave the
+// directory, such as "../secret", are rejected.
+//
+// Templates are parsed the first time they are used. Afterwards, the
+// modification times of the files are checked at most once per interval, and
+// templates whose file changed are parsed again, along with the templates
+// which include them as partials.
+//
+// It is safe to use a Cache from multiple goroutines.
+type Cache struct {
+	dir      string
+	interval time.Duration
+	options  []Option
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	loaded  []*cacheEntry // entries loaded since the last call to link
+	checked time.Time     // last time the files were checked for changes
+}
+
+type cacheEntry struct {
+	template *Template
+	modTime  time.Time
+	size     int64
+	partials []string // names of the partials included by the template
+	missing  []string // names of included partials which don't exist
+}
+
+// NewCache returns a cache of the templates in dir, which checks for changes
+// to the files at most once per interval. If interval is zero, files are
+// checked every time a template is used. The options are applied to every
+// template loaded by the cache.
+func NewCache(dir string, interval time.Duration, options ...Option) *Cache {
+	return &Cache{
+		dir:      dir,
+		interval: interval,
+		options:  options,
+		entries:  make(map[string]*cacheEntry),
+	}
+}
+
+// Lookup returns the template named name, parsing it and its partials if they
+// were not parsed already or changed since.
+func (c *Cache) Lookup(name string) (*Template, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.check()
+	e, err := c.load(name)
+	c.link()
+	if err != nil {
+		return nil, err
+	}
+	return e.template, nil
+}
+
+// Render renders the template named name to w, replacing the values found in
+// context.
+func (c *Cache) Render(name string, w io.Writer, context ...interface{}) error {
+	t, err := c.Lookup(name)
+	if err != nil {
+		return err
+	}
+	return t.Render(w, context...)
+}
+
+// path returns the path of the file of the template named name. Names are
+// slash-separated paths relative to the directory, and must not leave it, so
+// that names such as "../secret" are rejected.
+func (c *Cache) path(name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("invalid template name %q", name)
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(name)+".mustache"), nil
+}
+
+// load returns the entry of the template named name, parsing the template if
+// it's not cached. Entries are cached before their partials are loaded, so that
+// partials which include each other are linked to the same template.
+func (c *Cache) load(name string) (*cacheEntry, error) {
+	if e, ok := c.entries[name]; ok {
+		return e, nil
+	}
+	path, err := c.path(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := New(c.options...)
+	t.Option(Name(name))
+	if err := t.ParseBytes(b); err != nil {
+		return nil, fmt.Errorf("%s:%s", path, err)
+	}
+	e := &cacheEntry{template: t, modTime: info.ModTime(), size: info.Size()}
+	c.entries[name] = e
+	c.loaded = append(c.loaded, e)
+	seen := make(map[string]bool)
+	ast.Inspect(t.Tree(), func(n ast.Node) bool {
+		if p, ok := n.(*ast.Partial); ok && !seen[p.Name] {
+			seen[p.Name] = true
+			e.partials = append(e.partials, p.Name)
+		}
+		return true
+	})
+	for _, p := range e.partials {
+		pe, err := c.load(p)
+		if os.IsNotExist(err) {
+			// Missing partials render as nothing, the same way partials which
+			// are not registered with a template do.
+			e.missing = append(e.missing, p)
+			continue
+		}
+		if err != nil {
+			c.invalidate(name)
+			return nil, err
+		}
+		t.Option(Partial(pe.template))
+	}
+	return e, nil
+}
+
+// link registers with each newly loaded template every partial it includes,
+// directly or not. A partial is rendered with the partials of the template
+// that includes it, so the partials of partials must be known to the template
+// being rendered. Templates are linked before they are returned by Lookup, so
+// their partials are never modified while they are rendered.
+func (c *Cache) link() {
+	for _, e := range c.loaded {
+		seen := make(map[string]bool)
+		var walk func(*cacheEntry)
+		walk = func(pe *cacheEntry) {
+			for _, p := range pe.partials {
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				if pe, ok := c.entries[p]; ok {
+					e.template.Option(Partial(pe.template))
+					walk(pe)
+				}
+			}
+		}
+		walk(e)
+	}
+	c.loaded = nil
+}
+
+// check invalidates the templates whose file changed, or which include a
+// partial that was missing and now exists, if the interval has passed since
+// the files were last checked.
+func (c *Cache) check() {
+	if c.interval > 0 && time.Since(c.checked) < c.interval {
+		return
+	}
+	c.checked = time.Now()
+	var changed []string
+	for name, e := range c.entries {
+		path, _ := c.path(name) // names of entries are valid
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(e.modTime) || info.Size() != e.size {
+			changed = append(changed, name)
+			continue
+		}
+		for _, p := range e.missing {
+			path, _ := c.path(p) // missing partials have valid names
+			if _, err := os.Stat(path); err == nil {
+				changed = append(changed, name)
+				break
+			}
+		}
+	}
+	for _, name := range changed {
+		c.invalidate(name)
+	}
+}
+
+// invalidate removes the template named name from the cache, along with the
+// templates which include it, directly or not.
+func (c *Cache) invalidate(name string) {
+	if _, ok := c.entries[name]; !ok {
+		return
+	}
+	delete(c.entries, name)
+	for other, e := range c.entries {
+		for _, p := range e.partials {
+			if p == name {
+				c.invalidate(other)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modTime := time.Now().Add(-time.Hour)
+	write := func(name, s string) {
+		path := filepath.Join(dir, filepath.FromSlash(name)+".mustache")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Each write gets a distinct modification time, regardless of the
+		// resolution of the file system.
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := NewCache(dir, 0)
+	render := func(name, expected string) {
+		var b strings.Builder
+		if err := c.Render(name, &b, map[string]string{"name": "world"}); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, b.String())
+		}
+	}
+
+	write("pages/home", "{{>header}}Hello {{name}}{{>footer}}")
+	write("header", "[{{>title}}]")
+	write("title", "home")
+	render("pages/home", "[home]Hello world")
+
+	// A change to a partial is picked up by the templates including it.
+	write("title", "HOME")
+	render("pages/home", "[HOME]Hello world")
+
+	// A partial which was missing is picked up once it's created.
+	write("footer", "!")
+	render("pages/home", "[HOME]Hello world!")
+
+	write("pages/home", "Bye {{name}}")
+	render("pages/home", "Bye world")
+
+	write("header", "{{#oops}}")
+	if _, err := c.Lookup("header"); err == nil {
+		t.Errorf("expected a parse error")
+	}
+	if _, err := c.Lookup("missing"); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+
+	// Names can't leave the directory, including those of partials.
+	write("escape", "{{>../outside}}")
+	for _, name := range []string{"../outside", "/etc/passwd", "pages/../header", "escape"} {
+		if _, err := c.Lookup(name); err == nil || os.IsNotExist(err) {
+			t.Errorf("%s: expected an invalid name error, got %v", name, err)
+		}
+	}
+
+	// Partials may include each other.
+	write("a", "a{{#a}}{{>b}}{{/a}}")
+	write("b", "b{{#b}}{{>a}}{{/b}}")
+	tmpl, err := c.Lookup("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := tmpl.RenderString(map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"a": map[string]bool{"b": false},
+			},
+		},
+	})
+	if s != "abab" {
+		t.Errorf("expected %q, got %q", "abab", s)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "page.mustache")
+	if err := ioutil.WriteFile(path, []byte("{{>item}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "item.mustache"), []byte("{{name}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(dir, time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if i == 0 {
+					now := time.Now().Add(time.Duration(j) * time.Second)
+					os.Chtimes(path, now, now)
+				}
+				var b strings.Builder
+				if err := c.Render("page", &b, map[string]int{"name": j}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,523 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// translateSection is the name of the sections translated by the Translate
+// option, such as {{#t}}greeting{{/t}}.
+const translateSection = "t"
+
+// The Catalog type holds the translated messages of a set of locales, by key.
+// Messages are mustache templates, rendered with the options of the template
+// translating them, such as its filters, against the context of the section
+// translating them, and may select plural forms using the ICU syntax, such as
+//
+//	{count, plural, =0 {No messages} one {# message} other {# messages}}
+//
+// where # is replaced by the value of the count variable. The plural category
+// of a number, zero, one, two, few, many or other, follows the rules of the
+// Unicode CLDR for the language of the locale. Languages without known rules
+// use the rules of English.
+//
+// Messages may be set while templates using the catalog are rendered.
+type Catalog struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string // messages by locale, then by key
+	elems    map[messageSource][]node     // parsed messages
+}
+
+// The messageSource type identifies a parsed message by its source, and by
+// whether it was parsed with the filter extension.
+type messageSource struct {
+	source  string
+	filters bool
+}
+
+// NewCatalog returns a new, empty catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{
+		messages: make(map[string]map[string]string),
+		elems:    make(map[messageSource][]node),
+	}
+}
+
+// Set sets the message of key in locale.
+func (c *Catalog) Set(locale, key, message string) {
+	locale = strings.Replace(locale, "_", "-", -1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]string)
+	}
+	c.messages[locale][key] = message
+}
+
+// Message returns the message of key in locale. If locale has no message for
+// key, the message of its language is returned if there is one, so that a
+// catalog for "de" serves "de-AT" as well.
+func (c *Catalog) Message(locale, key string) (string, bool) {
+	locale = strings.Replace(locale, "_", "-", -1)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if m, ok := c.messages[locale][key]; ok {
+		return m, true
+	}
+	if i := strings.IndexByte(locale, '-'); i >= 0 {
+		m, ok := c.messages[locale[:i]][key]
+		return m, ok
+	}
+	return "", false
+}
+
+// LoadJSON loads the messages of locale from a JSON object read from r, whose
+// members are either messages or objects of messages. Keys of nested objects
+// are prefixed by the key of their parent and a dot, so that
+//
+//	{"nav": {"home": "Home"}}
+//
+// sets the message of nav.home.
+func (c *Catalog) LoadJSON(locale string, r io.Reader) error {
+	var v map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return err
+	}
+	return c.loadJSON(locale, "", v)
+}
+
+func (c *Catalog) loadJSON(locale, prefix string, v map[string]interface{}) error {
+	for k, v := range v {
+		switch v := v.(type) {
+		case string:
+			c.Set(locale, prefix+k, v)
+		case map[string]interface{}:
+			if err := c.loadJSON(locale, prefix+k+".", v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %s is not a string", prefix+k)
+		}
+	}
+	return nil
+}
+
+// LoadPO loads the messages of locale from a gettext .po file read from r. The
+// msgid of an entry is its key. Entries which are untranslated, marked as
+// fuzzy, or have a msgctxt are ignored. The translations of entries with a
+// msgid_plural are selected by the value of the count variable, and are the
+// plural categories of the language of locale in the order used by gettext,
+// such as one, few and many for Russian. An error is returned if the number of
+// translations, or the nplurals of the Plural-Forms header, doesn't match the
+// plural rules of the language.
+func (c *Catalog) LoadPO(locale string, r io.Reader) error {
+	var (
+		e       poEntry
+		field   *string
+		line    int
+		start   int // line of the entry
+		scanner = bufio.NewScanner(r)
+	)
+	flush := func() error {
+		defer func() { e, field = poEntry{}, nil }()
+		if e.fuzzy || e.context || len(e.strs) == 0 {
+			return nil
+		}
+		if e.id == "" {
+			return poHeader(locale, e.strs[0])
+		}
+		msg, ok, err := e.message(locale)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", start, err)
+		}
+		if ok {
+			c.Set(locale, e.id, msg)
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		line++
+		s := strings.TrimSpace(scanner.Text())
+		switch {
+		case s == "":
+			continue
+		case strings.HasPrefix(s, "#,"):
+			if len(e.strs) > 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			e.fuzzy = strings.Contains(s, "fuzzy")
+			continue
+		case strings.HasPrefix(s, "#"):
+			continue
+		case strings.HasPrefix(s, `"`):
+			if field == nil {
+				return fmt.Errorf("line %d: string outside of an entry", line)
+			}
+			v, err := strconv.Unquote(s)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", line, err)
+			}
+			*field += v
+			continue
+		}
+		keyword, value := s, ""
+		if i := strings.IndexAny(s, " \t"); i >= 0 {
+			keyword, value = s[:i], strings.TrimSpace(s[i:])
+		}
+		v, err := strconv.Unquote(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+		switch {
+		case keyword == "msgctxt", keyword == "msgid":
+			if len(e.strs) > 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if keyword == "msgctxt" {
+				e.context, start = true, line
+				field = new(string)
+				break
+			}
+			if !e.context {
+				start = line
+			}
+			field = &e.id
+		case keyword == "msgid_plural":
+			field = &e.plural
+		case keyword == "msgstr":
+			e.strs = append(e.strs, "")
+			field = &e.strs[len(e.strs)-1]
+		case strings.HasPrefix(keyword, "msgstr["):
+			n, err := strconv.Atoi(strings.TrimSuffix(keyword[len("msgstr["):], "]"))
+			if err != nil || n != len(e.strs) {
+				return fmt.Errorf("line %d: unexpected %s", line, keyword)
+			}
+			e.strs = append(e.strs, "")
+			field = &e.strs[n]
+		default:
+			return fmt.Errorf("line %d: unknown keyword %s", line, keyword)
+		}
+		*field = v
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// poHeader checks that the Plural-Forms of the header of a .po file, if any,
+// match the plural rules of the language of locale.
+func poHeader(locale, header string) error {
+	for _, line := range strings.Split(header, "\n") {
+		name := "Plural-Forms:"
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		for _, field := range strings.Split(line[len(name):], ";") {
+			field = strings.TrimSpace(field)
+			if !strings.HasPrefix(field, "nplurals=") {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimPrefix(field, "nplurals="))
+			if err != nil {
+				return fmt.Errorf("invalid Plural-Forms %q", line)
+			}
+			if forms := len(pluralRuleOf(locale).forms); n != forms {
+				return fmt.Errorf("header has %d plural forms, the plural rules of %s have %d", n, locale, forms)
+			}
+		}
+	}
+	return nil
+}
+
+// The poEntry type is an entry of a .po file.
+type poEntry struct {
+	id      string
+	plural  string
+	strs    []string
+	fuzzy   bool
+	context bool
+}
+
+// message returns the message of the entry, which is a plural message of the
+// count variable if the entry has plural forms. If the entry is untranslated,
+// ok is false.
+func (e poEntry) message(locale string) (msg string, ok bool, err error) {
+	for _, s := range e.strs {
+		if s == "" {
+			return "", false, nil
+		}
+	}
+	if e.plural == "" {
+		return e.strs[0], true, nil
+	}
+	rule := pluralRuleOf(locale)
+	if len(e.strs) != len(rule.forms) {
+		return "", false, fmt.Errorf("entry %s has %d plural forms, the plural rules of %s have %d", e.id, len(e.strs), locale, len(rule.forms))
+	}
+	var b strings.Builder
+	b.WriteString("{count, plural,")
+	for i, category := range rule.forms {
+		fmt.Fprintf(&b, " %s {%s}", category, e.strs[i])
+	}
+	if rule.forms[len(rule.forms)-1] != "other" {
+		fmt.Fprintf(&b, " other {%s}", e.strs[len(e.strs)-1])
+	}
+	b.WriteString("}")
+	return b.String(), true, nil
+}
+
+// Translate translates the text of {{#t}} sections, such as
+// {{#t}}greeting{{/t}}, using the messages of locale in c. The message is
+// rendered as a template against the context of the section, so that it may
+// include variables such as {{name}}. A key without a message renders as is,
+// and stops the rendering unless misses are silent.
+//
+// If locale is empty, the locale set by the Locale option is used, so that
+// messages, their plural forms and the numbers in them follow the same locale.
+// Plural messages render # using the formatters of the template, so that the
+// Locale option formats their numbers as well.
+func Translate(c *Catalog, locale string) Option {
+	return func(t *Template) {
+		t.catalog = c
+		t.catalogLocale = locale
+	}
+}
+
+// messageLocale returns the locale of the messages translated by t.
+func (t *Template) messageLocale() string {
+	if t.catalogLocale != "" {
+		return t.catalogLocale
+	}
+	return t.locale
+}
+
+// translate renders the translation of the text of the elements of a {{#t}}
+// section.
+func (t *Template) translate(elems []node, w *writer, c ...interface{}) error {
+	var key strings.Builder
+	for _, elem := range elems {
+		text, ok := elem.(textNode)
+		if !ok {
+			return fmt.Errorf("translated section %s contains tags", translateSection)
+		}
+		key.WriteString(string(text))
+	}
+	k := strings.TrimSpace(key.String())
+	message, found := t.catalog.Message(t.messageLocale(), k)
+	if !found {
+		message = k
+	}
+	plurals := make(pluralValues)
+	source, err := t.plural(message, c, plurals)
+	if err != nil {
+		return err
+	}
+	messageElems, err := t.catalog.parse(source, t.filters != nil)
+	if err != nil {
+		return fmt.Errorf("message %s: %s", k, err)
+	}
+	if len(plurals) > 0 {
+		c = append([]interface{}{plurals}, c...)
+	}
+	for _, elem := range messageElems {
+		// Misses within messages follow the template, the same way they do in
+		// Template.render.
+		if err := elem.render(t, w, c...); err != nil && (!t.silentMiss || fatal(err)) {
+			return err
+		}
+		if err := w.limit.check(); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("failed to translate %s", k)
+	}
+	return nil
+}
+
+// parse parses the message source, with the filter extension if filters is
+// true, caching the result so that messages are parsed once. The source of a
+// message depends only on the plural forms it selects, so the cache is bounded
+// by the forms of the messages.
+func (c *Catalog) parse(source string, filters bool) ([]node, error) {
+	key := messageSource{source, filters}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elems, ok := c.elems[key]; ok {
+		return elems, nil
+	}
+	l := newLexer(source, "{{", "}}")
+	l.filters = filters
+	tree, err := newParser(l).parseTree()
+	if err != nil {
+		return nil, err
+	}
+	elems := compile(tree)
+	c.elems[key] = elems
+	return elems, nil
+}
+
+// pluralArg matches the start of a plural argument of a message, such as
+// {count, plural,
+var pluralArg = regexp.MustCompile(`^\{\s*([\w.@-]+)\s*,\s*plural\s*,`)
+
+// The pluralValues type holds the values of the plural arguments of a message,
+// which are pushed onto the context chain of the message so that # can refer
+// to them, by names such as @plural0 that can't be used otherwise.
+type pluralValues map[string]interface{}
+
+// plural replaces the plural arguments of message by the forms selected by the
+// values of their variables in the context chain c, and records the values in
+// plurals.
+func (t *Template) plural(message string, c []interface{}, plurals pluralValues) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(message); {
+		if strings.HasPrefix(message[i:], "{{") {
+			j := strings.Index(message[i:], "}}")
+			if j < 0 {
+				b.WriteString(message[i:])
+				break
+			}
+			b.WriteString(message[i : i+j+2])
+			i += j + 2
+			continue
+		}
+		m := pluralArg.FindStringSubmatch(message[i:])
+		if m == nil {
+			b.WriteByte(message[i])
+			i++
+			continue
+		}
+		end := closingBrace(message, i)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed plural argument %s", m[1])
+		}
+		form, err := t.pluralForm(m[1], message[i+len(m[0]):end], c, plurals)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(form)
+		i = end + 1
+	}
+	return b.String(), nil
+}
+
+// pluralForm returns the form of the plural argument name whose cases are
+// given, selected by the value of name in the context chain c. The # of the
+// form is replaced by a tag rendering the value, recorded in plurals.
+func (t *Template) pluralForm(name, cases string, c []interface{}, plurals pluralValues) (string, error) {
+	v, _, err := t.lookup(name, c...)
+	if err != nil {
+		return "", err
+	}
+	n, ok := toFloat(v)
+	if !ok {
+		return "", fmt.Errorf("plural argument %s is not a number", name)
+	}
+	forms := make(map[string]string)
+	for s := strings.TrimSpace(cases); s != ""; s = strings.TrimSpace(s) {
+		i := strings.IndexByte(s, '{')
+		if i <= 0 {
+			return "", fmt.Errorf("malformed plural argument %s", name)
+		}
+		end := closingBrace(s, i)
+		if end < 0 {
+			return "", fmt.Errorf("unclosed case of plural argument %s", name)
+		}
+		selector := strings.TrimSpace(s[:i])
+		if !pluralCategories[selector] && !strings.HasPrefix(selector, "=") {
+			return "", fmt.Errorf("unknown plural category %s of plural argument %s", selector, name)
+		}
+		forms[selector] = s[i+1 : end]
+		s = s[end+1:]
+	}
+	form, ok := forms["="+strconv.FormatFloat(n, 'f', -1, 64)]
+	if !ok {
+		if form, ok = forms[pluralCategory(t.messageLocale(), n)]; !ok {
+			if form, ok = forms["other"]; !ok {
+				return "", fmt.Errorf("plural argument %s has no other case", name)
+			}
+		}
+	}
+	arg := "@plural" + strconv.Itoa(len(plurals))
+	plurals[arg] = v
+	form = replaceHash(form, "{{&"+arg+"}}")
+	return t.plural(form, c, plurals)
+}
+
+// closingBrace returns the index of the brace closing the one at index i of s,
+// or -1.
+func closingBrace(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// replaceHash replaces # by tag in form, except within tags, so that sections
+// such as {{#items}} are left unchanged.
+func replaceHash(form, tag string) string {
+	var b strings.Builder
+	for i := 0; i < len(form); {
+		if strings.HasPrefix(form[i:], "{{") {
+			j := strings.Index(form[i:], "}}")
+			if j < 0 {
+				b.WriteString(form[i:])
+				break
+			}
+			b.WriteString(form[i : i+j+2])
+			i += j + 2
+			continue
+		}
+		if form[i] == '#' {
+			b.WriteString(tag)
+		} else {
+			b.WriteByte(form[i])
+		}
+		i++
+	}
+	return b.String()
+}
+
+// toFloat converts the number v to a float64.
+func toFloat(v interface{}) (float64, bool) {
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(r.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(r.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return r.Float(), true
+	}
+	return 0, false
+}
+
+// language returns the language of locale, such as "de" for "de-AT".
+func language(locale string) string {
+	locale = strings.Replace(locale, "_", "-", -1)
+	return strings.ToLower(strings.SplitN(locale, "-", 2)[0])
+}
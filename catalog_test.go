@@ -0,0 +1,296 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testPO = `# German translations.
+msgid ""
+msgstr ""
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+msgid "greeting"
+msgstr "Hallo {{name}}!"
+
+#, fuzzy
+msgid "farewell"
+msgstr "Tschüss"
+
+msgctxt "menu"
+msgid "open"
+msgstr "Öffnen"
+
+msgid "untranslated"
+msgstr ""
+
+msgid "inbox"
+msgid_plural "inbox"
+msgstr[0] "Eine Nachricht"
+msgstr[1] ""
+"# Nachrichten"
+`
+
+const testJSON = `{
+	"greeting": "Bonjour {{name}} !",
+	"inbox": "{count, plural, =0 {Aucun message} one {# message} other {# messages}}",
+	"nav": {"home": "Accueil"}
+}`
+
+func TestTranslate(t *testing.T) {
+	c := NewCatalog()
+	if err := c.LoadPO("de", strings.NewReader(testPO)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadJSON("fr_FR", strings.NewReader(testJSON)); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("en", "inbox", "{{#user}}{{name}}, you have {count, plural, one {# message} other {# messages}}{{/user}}")
+	for _, test := range []struct {
+		locale   string
+		template string
+		context  interface{}
+		expected string
+	}{
+		{"de-DE", "{{#t}}greeting{{/t}}", map[string]string{"name": "Welt"}, "Hallo Welt!"},
+		{"de-AT", "{{#t}}inbox{{/t}} {{#t}} inbox {{/t}}", map[string]int{"count": 1}, "Eine Nachricht Eine Nachricht"},
+		{"de", "{{#t}}inbox{{/t}}", map[string]int{"count": 2000}, "2000 Nachrichten"},
+		{"de", "{{#t}}farewell{{/t}}|{{#t}}open{{/t}}|{{#t}}untranslated{{/t}}", nil, "farewell|open|untranslated"},
+		{"fr-FR", "{{#t}}greeting{{/t}}", map[string]string{"name": "<Marie>"}, "Bonjour &lt;Marie&gt; !"},
+		{"fr-FR", "{{#t}}inbox{{/t}}", map[string]int{"count": 0}, "Aucun message"},
+		{"fr-FR", "{{#t}}inbox{{/t}}", map[string]float64{"count": 1.5}, "1.5 message"},
+		{"fr-FR", "{{#t}}inbox{{/t}}, {{#t}}nav.home{{/t}}", map[string]int{"count": 3}, "3 messages, Accueil"},
+		{"en", "{{#t}}inbox{{/t}}", map[string]interface{}{"count": 1, "user": map[string]string{"name": "Ann"}}, "Ann, you have 1 message"},
+		{"en", "{{#t}}inbox{{/t}}", map[string]interface{}{"count": 1.5, "user": map[string]string{"name": "Ann"}}, "Ann, you have 1.5 messages"},
+		{"en", "{{^t}}none{{/t}}", nil, "none"},
+	} {
+		template := New(Translate(c, test.locale))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(test.context)
+		if err != nil {
+			t.Error(err)
+		}
+		if output != test.expected {
+			t.Errorf("%s %s: expected %q got %q", test.locale, test.template, test.expected, output)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	c := NewCatalog()
+	c.Set("en", "inbox", "{count, plural, one {# message}}")
+	c.Set("en", "unclosed", "{count, plural, other {# messages}")
+	c.Set("en", "unknown", "{count, plural, several {# messages} other {# messages}}")
+	c.Set("en", "greeting", "Hello {{name}}")
+	for _, test := range []struct {
+		template string
+		context  interface{}
+		err      string
+	}{
+		{"{{#t}}missing{{/t}}", nil, "failed to translate missing"},
+		{"{{#t}}{{name}}{{/t}}", nil, "translated section t contains tags"},
+		{"{{#t}}inbox{{/t}}", map[string]int{"count": 2}, "plural argument count has no other case"},
+		{"{{#t}}inbox{{/t}}", map[string]string{"count": "two"}, "plural argument count is not a number"},
+		{"{{#t}}unclosed{{/t}}", map[string]int{"count": 2}, "unclosed plural argument count"},
+		{"{{#t}}unknown{{/t}}", map[string]int{"count": 2}, "unknown plural category several of plural argument count"},
+		{"{{#t}}greeting{{/t}}", nil, "failed to lookup name"},
+	} {
+		template := New(Translate(c, "en"), SilentMiss(false))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		_, err := template.RenderString(test.context)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: expected error %q got %v", test.template, test.err, err)
+		}
+	}
+}
+
+func TestTranslateLocale(t *testing.T) {
+	c := NewCatalog()
+	c.Set("de", "total", "{count, plural, one {# Artikel} other {# Artikel}} für {{price}}")
+	template := New(Translate(c, "de-DE"), Locale("de-DE"))
+	if err := template.ParseString("{{#t}}total{{/t}}"); err != nil {
+		t.Fatal(err)
+	}
+	output, err := template.RenderString(map[string]interface{}{"count": 1500, "price": Money{12.5, "EUR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "1.500 Artikel für 12,50 €"; output != expected {
+		t.Errorf("expected %q got %q", expected, output)
+	}
+}
+
+func TestTranslateFilters(t *testing.T) {
+	c := NewCatalog()
+	c.Set("en", "greeting", "Hello {{name | upper}}, {count, plural, one {# message} other {# messages}}")
+	template := New(Filters(nil), Translate(c, "en"))
+	if err := template.ParseString("{{#t}}greeting{{/t}}"); err != nil {
+		t.Fatal(err)
+	}
+	output, err := template.RenderString(map[string]interface{}{"name": "ann", "count": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Hello ANN, 2 messages"; output != expected {
+		t.Errorf("expected %q got %q", expected, output)
+	}
+}
+
+func TestCheckTranslate(t *testing.T) {
+	template := New(Translate(NewCatalog(), "en"))
+	if err := template.ParseString("{{#t}}greeting{{/t}}{{Titel}}"); err != nil {
+		t.Fatal(err)
+	}
+	err := template.Check(reflect.TypeOf(checkPage{}))
+	errs, ok := err.(CheckErrors)
+	if !ok || len(errs) != 1 || errs[0].Name != "Titel" {
+		t.Errorf("expected an error for Titel, got %v", err)
+	}
+}
+
+func TestTranslateCache(t *testing.T) {
+	c := NewCatalog()
+	c.Set("en", "inbox", "{count, plural, =0 {No messages} one {# message} other {# messages}}")
+	template := New(Translate(c, "en"))
+	if err := template.ParseString("{{#t}}inbox{{/t}}"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		output, err := template.RenderString(map[string]int{"count": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 1 && output != strconv.Itoa(i)+" messages" {
+			t.Errorf("unexpected output %q", output)
+		}
+	}
+	if len(c.elems) != 3 {
+		t.Errorf("expected a parsed message for each of the 3 forms, got %d", len(c.elems))
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	for _, test := range []struct {
+		locale   string
+		n        float64
+		expected string
+	}{
+		{"en-US", 1, "one"},
+		{"en-US", 1.5, "other"},
+		{"en-US", 0, "other"},
+		{"xx", 1, "one"},
+		{"fr-FR", 1.5, "one"},
+		{"fr-FR", 2000000, "many"},
+		{"pt-BR", 0, "one"},
+		{"pt_PT", 0, "other"},
+		{"ja", 1, "other"},
+		{"ru", 21, "one"},
+		{"ru", 11, "many"},
+		{"ru", 23, "few"},
+		{"ru", 12, "many"},
+		{"ru", 1.5, "other"},
+		{"pl", 21, "many"},
+		{"pl", 22, "few"},
+		{"cs", 3, "few"},
+		{"cs", 5, "other"},
+		{"cs", 0.5, "many"},
+		{"he", 2, "two"},
+		{"ar", 0, "zero"},
+		{"ar", 103, "few"},
+		{"ar", 111, "many"},
+		{"ar", 100, "other"},
+	} {
+		if category := pluralCategory(test.locale, test.n); category != test.expected {
+			t.Errorf("%s %v: expected %s got %s", test.locale, test.n, test.expected, category)
+		}
+	}
+}
+
+func TestLoadPOPlurals(t *testing.T) {
+	c := NewCatalog()
+	po := `msgid ""
+msgstr "Plural-Forms: nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);\n"
+
+msgid "files"
+msgid_plural "files"
+msgstr[0] "# файл"
+msgstr[1] "# файла"
+msgstr[2] "# файлов"
+`
+	if err := c.LoadPO("ru", strings.NewReader(po)); err != nil {
+		t.Fatal(err)
+	}
+	template := New(Translate(c, "ru-RU"))
+	if err := template.ParseString("{{#t}}files{{/t}}"); err != nil {
+		t.Fatal(err)
+	}
+	for n, expected := range map[float64]string{1: "1 файл", 3: "3 файла", 5: "5 файлов", 1.5: "1.5 файлов"} {
+		output, err := template.RenderString(map[string]float64{"count": n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != expected {
+			t.Errorf("%v: expected %q got %q", n, expected, output)
+		}
+	}
+
+	for _, test := range []struct {
+		locale string
+		po     string
+		err    string
+	}{
+		{"ru", "msgid \"a\"\nmsgid_plural \"a\"\nmsgstr[0] \"x\"\nmsgstr[1] \"y\"\n", "line 1: entry a has 2 plural forms, the plural rules of ru have 3"},
+		{"de", "msgid \"\"\nmsgstr \"Plural-Forms: nplurals=3; plural=n;\\n\"\n", "header has 3 plural forms, the plural rules of de have 2"},
+	} {
+		err := NewCatalog().LoadPO(test.locale, strings.NewReader(test.po))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected error %q, got %v", test.err, err)
+		}
+	}
+}
+
+func TestCatalogConcurrent(t *testing.T) {
+	c := NewCatalog()
+	c.Set("en", "greeting", "Hello")
+	template := New(Translate(c, "en"))
+	if err := template.ParseString("{{#t}}greeting{{/t}}"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			c.Set("en", "greeting", "Hello "+strconv.Itoa(i))
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := template.RenderString(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
+
+func TestTranslateDefaultLocale(t *testing.T) {
+	c := NewCatalog()
+	c.Set("ru", "files", "{count, plural, one {# файл} few {# файла} many {# файлов} other {# файла}}")
+	template := New(Locale("ru"), Translate(c, ""))
+	if err := template.ParseString("{{#t}}files{{/t}}"); err != nil {
+		t.Fatal(err)
+	}
+	output, err := template.RenderString(map[string]int{"count": 22})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "22 файла"; output != expected {
+		t.Errorf("expected %q got %q", expected, output)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+// The CheckError type describes a name which can never be resolved by lookup.
+type CheckError struct {
+	Template string       // name of the template, or partial, using the name
+	Pos      ast.Pos      // position of the tag using the name
+	Name     string       // the name as it appears in the tag
+	Type     reflect.Type // type of the context the name was looked up in
+}
+
+func (e *CheckError) Error() string {
+	if e.Template != "" {
+		return fmt.Sprintf("%s:%s: failed to lookup %s in %s", e.Template, e.Pos, e.Name, e.Type)
+	}
+	return fmt.Sprintf("%s: failed to lookup %s in %s", e.Pos, e.Name, e.Type)
+}
+
+// The CheckErrors type is a list of every name reported by Check.
+type CheckErrors []*CheckError
+
+func (e CheckErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Check reports every name used by the template that lookup could never
+// resolve when rendering the template with a context of type typ. Sections are
+// followed into the element type of slices and arrays, as well as into the
+// partials registered with the template. Names looked up in maps or interfaces
+// can only be resolved when rendering, so they are never reported, and
+// neither are the names used by the messages of sections translated by the
+// Translate option.
+//
+// If any names can't be resolved, the returned error is of type CheckErrors.
+func (t *Template) Check(typ reflect.Type) error {
+	c := &checker{partials: t.partials, iteration: t.iteration, translate: t.catalog != nil, active: make(map[string]bool)}
+	c.nodes(t.name, t.tree, []reflect.Type{typ})
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+// The checker type holds the state of a Check.
+type checker struct {
+	partials  map[string]*Template
+	iteration bool            // whether iteration metadata is available.
+	translate bool            // whether {{#t}} sections are translated.
+	active    map[string]bool // partials being checked, to avoid recursion.
+	errs      CheckErrors
+}
+
+// nodes checks each of the nodes using chain as the context types. A nil type
+// in chain is a context whose type is only known when rendering.
+func (c *checker) nodes(name string, nodes []ast.Node, chain []reflect.Type) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Var:
+			c.lookup(name, n.Start, n.Name, chain)
+		case *ast.Section:
+			if c.translate && n.Name == translateSection && !n.Inverted {
+				continue
+			}
+			typ, ok := c.lookup(name, n.Start, n.Name, chain)
+			if !ok {
+				continue
+			}
+			// Inverted sections are rendered with the value itself, such as an
+			// empty slice, pushed onto the context.
+			inner := append([]reflect.Type{typ}, chain...)
+			if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && !n.Inverted {
+				inner = append([]reflect.Type{typ.Elem()}, chain...)
+				if c.iteration {
+					inner = append([]reflect.Type{typ.Elem(), iterationType}, chain...)
+				}
+			}
+			c.nodes(name, n.Nodes, inner)
+		case *ast.Partial:
+			p, ok := c.partials[n.Name]
+			if !ok || c.active[n.Name] {
+				continue
+			}
+			c.active[n.Name] = true
+			c.nodes(n.Name, p.tree, chain)
+			c.active[n.Name] = false
+		}
+	}
+}
+
+// lookup resolves name in chain the same way the lookup function would. If
+// name can't be resolved an error is recorded and ok is false.
+func (c *checker) lookup(template string, pos ast.Pos, name string, chain []reflect.Type) (typ reflect.Type, ok bool) {
+	parts := []string{name}
+	if name != "." {
+		parts = strings.Split(name, ".")
+	}
+	for i, part := range parts {
+		if i > 0 {
+			chain = []reflect.Type{typ}
+		}
+		typ, ok = lookupType(part, chain...)
+		if !ok {
+			c.errs = append(c.errs, &CheckError{template, pos, name, chain[0]})
+			return nil, false
+		}
+	}
+	return typ, true
+}
+
+// lookupType searches for a property that matches name within the chain of
+// context types, following the same rules as lookup. If the name may only be
+// resolved when rendering, a nil type is returned along with a positive truth.
+func lookupType(name string, chain ...reflect.Type) (reflect.Type, bool) {
+	for _, typ := range chain {
+		if typ == nil {
+			return nil, true
+		}
+		if name == "." {
+			return typ, true
+		}
+		switch typ.Kind() {
+		case reflect.Interface:
+			return nil, true
+		case reflect.Map:
+			if typ == iterationType {
+				if t, ok := iterationTypes[name]; ok {
+					return t, true
+				}
+				continue
+			}
+			if len(mapKeys(name, typ.Key())) > 0 {
+				return typ.Elem(), true
+			}
+		case reflect.Slice:
+			// The length of slices is only known when rendering.
+			if _, err := strconv.Atoi(name); err == nil {
+				return typ.Elem(), true
+			}
+		case reflect.Array:
+			if _, ok := index(name, typ.Len(), true); ok {
+				return typ.Elem(), true
+			}
+		case reflect.Struct:
+			if field, ok := typ.FieldByName(name); ok && field.PkgPath == "" {
+				return field.Type, true
+			}
+			// Methods of a struct type take the receiver as their first
+			// argument.
+			if method, ok := typ.MethodByName(name); ok && method.Type.NumIn() == 1 && method.Type.NumOut() > 0 {
+				return method.Type.Out(0), true
+			}
+			for i := 0; i < typ.NumField(); i++ {
+				if typ.Field(i).Tag.Get("template") == name {
+					return typ.Field(i).Type, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
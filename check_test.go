@@ -0,0 +1,95 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+type checkPage struct {
+	Title  string
+	Items  []checkItem
+	Meta   map[string]string
+	Extra  interface{}
+	Author struct {
+		Name string `template:"name"`
+	}
+}
+
+type checkItem struct {
+	Name  string
+	price float64
+}
+
+func (i checkItem) Price() float64 { return i.price }
+
+func TestCheck(t *testing.T) {
+	item := New(Name("item"))
+	if err := item.ParseString("{{Name}} {{Price}} {{Titel}}{{>item}}"); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		template string
+		errors   []string
+	}{
+		{"{{Title}} {{Author.name}} {{Meta.anything}} {{Extra.anything}}", nil},
+		{"{{#Items}}{{Name}} {{Price}} {{Title}} {{.}}{{/Items}}", nil},
+		{"{{#Items}}{{>item}}{{/Items}}", []string{"item:1:20: failed to lookup Titel in mustache.checkItem"}},
+		{"{{^Items}}{{Title}}{{Name}}{{/Items}}", []string{"1:20: failed to lookup Name in []mustache.checkItem"}},
+		{
+			"{{Titel}}\n{{#Items}}{{price}}{{/Items}}{{Author.Nme}}{{^Missing}}{{Anything}}{{/Missing}}",
+			[]string{
+				"1:1: failed to lookup Titel in mustache.checkPage",
+				"2:11: failed to lookup price in mustache.checkItem",
+				"2:30: failed to lookup Author.Nme in struct { Name string \"template:\\\"name\\\"\" }",
+				"2:44: failed to lookup Missing in mustache.checkPage",
+			},
+		},
+	} {
+		template := New(Partial(item))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		err := template.Check(reflect.TypeOf(checkPage{}))
+		if test.errors == nil {
+			if err != nil {
+				t.Errorf("%q: unexpected error %s", test.template, err)
+			}
+			continue
+		}
+		errs, ok := err.(CheckErrors)
+		if !ok {
+			t.Fatalf("%q: unexpected error %v", test.template, err)
+		}
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		if !reflect.DeepEqual(messages, test.errors) {
+			t.Errorf("%q: unexpected errors %q, expected %q", test.template, messages, test.errors)
+		}
+	}
+}
+
+func TestCheckIndex(t *testing.T) {
+	type indexed struct {
+		Items  []checkItem
+		Matrix [2][3]int
+		IDs    map[int]string
+	}
+	template := New()
+	if err := template.ParseString("{{Items.0.Name}}{{Items.1.Nme}}{{Matrix.1.2}}{{Matrix.2}}{{IDs.1}}{{IDs.x}}"); err != nil {
+		t.Fatal(err)
+	}
+	err := template.Check(reflect.TypeOf(indexed{}))
+	errs, ok := err.(CheckErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	for i, name := range []string{"Items.1.Nme", "Matrix.2", "IDs.x"} {
+		if errs[i].Name != name {
+			t.Errorf("expected an error for %s, got %s", name, errs[i])
+		}
+	}
+}
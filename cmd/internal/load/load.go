@@ -0,0 +1,88 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Package load implements the loading of templates and Go types shared by the
+// mustache commands.
+package load
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexkappa/mustache"
+)
+
+// Template parses the template in file and names it after the file's base name
+// without its extension. Syntax errors are returned wrapping the
+// *mustache.ParseError, prefixed by the name of the file.
+func Template(file string, options ...mustache.Option) (*mustache.Template, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(file)
+	t := mustache.New(options...)
+	t.Option(mustache.Name(strings.TrimSuffix(base, filepath.Ext(base))))
+	if err := t.ParseBytes(b); err != nil {
+		return nil, fmt.Errorf("%s:%w", file, err)
+	}
+	return t, nil
+}
+
+// Partials parses the *.mustache files of dir the same way as Template, and
+// sets them as partials of t.
+func Partials(t *mustache.Template, dir string, options ...mustache.Option) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.mustache"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		p, err := Template(file, options...)
+		if err != nil {
+			return err
+		}
+		t.Option(mustache.Partial(p))
+	}
+	return nil
+}
+
+// Type type checks the package in dir and returns its named type name. Test
+// files are skipped, along with the files for which skip returns true, if it
+// isn't nil. Errors in unrelated parts of the package are ignored.
+func Type(dir, name string, skip func(file string) bool) (*types.Named, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		n := fi.Name()
+		return !strings.HasSuffix(n, "_test.go") && (skip == nil || !skip(n))
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	for pkgName, pkg := range pkgs {
+		var files []*ast.File
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+		conf := types.Config{
+			Importer: importer.Default(),
+			Error:    func(error) {},
+		}
+		p, _ := conf.Check(pkgName, fset, files, nil)
+		if p == nil {
+			continue
+		}
+		if obj, ok := p.Scope().Lookup(name).(*types.TypeName); ok {
+			if named, ok := obj.Type().(*types.Named); ok {
+				return named, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("type %s not found in %s", name, dir)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Command mustache-gen compiles a mustache template to a Go function which
+// renders the template without using reflection.
+//
+// It is meant to be used with go:generate. For example, given a type Page
+// declared in the current package, the following directive
+//
+//	//go:generate mustache-gen -type Page -partials partials page.mustache
+//
+// generates the file page_mustache.go declaring
+//
+//	func RenderPage(w io.Writer, data *Page) error
+//
+// Every name used in the template is resolved against Page when generating the
+// code, so a name which does not exist on Page is reported as an error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexkappa/mustache/cmd/internal/load"
+)
+
+var (
+	typeName = flag.String("type", "", "name of the context type; must be set")
+	funcName = flag.String("func", "", "name of the generated function; default Render<type>")
+	output   = flag.String("o", "", "output file name; default <template>_mustache.go")
+	dir      = flag.String("dir", ".", "directory of the package declaring the context type")
+	partials = flag.String("partials", "", "directory of *.mustache files used as partials")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: mustache-gen -type T [flags] template.mustache\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *typeName == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *funcName == "" {
+		*funcName = "Render" + *typeName
+	}
+	name := flag.Arg(0)
+	if *output == "" {
+		*output = strings.TrimSuffix(name, filepath.Ext(name)) + "_mustache.go"
+	}
+	if err := generate(name); err != nil {
+		fmt.Fprintf(os.Stderr, "mustache-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(name string) error {
+	// Previously generated files are skipped so that stale code does not
+	// prevent the package from being checked.
+	typ, err := load.Type(*dir, *typeName, func(file string) bool {
+		return strings.HasSuffix(file, "_mustache.go") || file == filepath.Base(*output)
+	})
+	if err != nil {
+		return err
+	}
+	t, err := load.Template(name)
+	if err != nil {
+		return err
+	}
+	if *partials != "" {
+		if err := load.Partials(t, *partials); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := t.Generate(f, *funcName, typ); err != nil {
+		f.Close()
+		os.Remove(*output)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Command mustache-lsp is a language server for mustache templates speaking
+// the Language Server Protocol over the standard input and output.
+//
+// It publishes diagnostics for templates which fail to parse or which are
+// reported by the lint package, resolves {{>partial}} tags to the partial's
+// file, lists sections as document symbols and completes variable names.
+//
+// The server is configured using the initializationOptions of the initialize
+// request, all of which are optional:
+//
+//	{
+//		"partials": "path/to/partials",  // directory of partials
+//		"data":     "path/to/data.json", // sample data used for completion
+//		"goDir":    "path/to/package",   // package declaring goType
+//		"goType":   "Page"               // Go type used for completion
+//	}
+//
+// Partials are looked up as <name>.mustache files in the partials directory,
+// or next to the template if unset. Without data or a Go type, names already
+// used by the template are completed.
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mustache-lsp: ")
+	s := newServer(os.Stdin, os.Stdout)
+	if err := s.serve(); err != nil {
+		log.Fatal(err)
+	}
+}
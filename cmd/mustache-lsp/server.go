@@ -0,0 +1,416 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alexkappa/mustache/lint"
+)
+
+// The server type holds the state of the language server.
+type server struct {
+	in      *bufio.Reader
+	out     io.Writer
+	root    string               // root directory of the workspace
+	options initializeOptions    // options sent by the client
+	data    shape                // shape of the data used for completion, if any
+	docs    map[string]*document // open documents by URI
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{
+		in:   bufio.NewReader(r),
+		out:  w,
+		docs: make(map[string]*document),
+	}
+}
+
+// serve handles messages until the client sends the exit notification or
+// closes the connection.
+func (s *server) serve() error {
+	for {
+		msg, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if e, ok := err.(*responseError); ok {
+				// The id of a request which can't be parsed is unknown, and
+				// must be null in the response.
+				null := json.RawMessage("null")
+				s.reply(&null, nil, e)
+				continue
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			if err != nil {
+				log.Printf("%s: %s", msg.Method, err)
+			}
+			continue
+		}
+		e, _ := err.(*responseError)
+		if err != nil && e == nil {
+			e = &responseError{codeInvalidParams, err.Error()}
+		}
+		if err := s.reply(msg.ID, result, e); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}, e *responseError) error {
+	m := &message{ID: id, Error: e}
+	if e == nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		m.Result = b
+	}
+	return writeMessage(s.out, m)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: b})
+}
+
+// handle handles m and returns the result of requests.
+func (s *server) handle(m *message) (interface{}, error) {
+	switch m.Method {
+	case "initialize":
+		var p initializeParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(p)
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		s.docs[p.TextDocument.URI] = newDocument(p.TextDocument.Text)
+		return nil, s.publish(p.TextDocument.URI)
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		// Only full synchronization is advertised, so the last change holds
+		// the whole text of the document.
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[p.TextDocument.URI] = newDocument(p.ContentChanges[n-1].Text)
+		}
+		return nil, s.publish(p.TextDocument.URI)
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(p), nil
+	case "textDocument/documentSymbol":
+		var p struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.symbols(p.TextDocument.URI), nil
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(p), nil
+	}
+	return nil, &responseError{codeMethodNotFound, "method not found: " + m.Method}
+}
+
+func (s *server) initialize(p initializeParams) (interface{}, error) {
+	s.options = p.InitializationOptions
+	s.root = uriPath(p.RootURI)
+	if s.root == "" {
+		s.root, _ = os.Getwd()
+	}
+	var err error
+	switch {
+	case s.options.Data != "":
+		s.data, err = loadJSON(s.path(s.options.Data))
+	case s.options.GoType != "":
+		s.data, err = loadGoType(s.path(s.options.GoDir), s.options.GoType)
+	}
+	if err != nil {
+		// Completion falls back to the names used by the template, which
+		// is preferable to failing the initialization.
+		log.Print(err)
+	}
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       1,
+			"definitionProvider":     true,
+			"documentSymbolProvider": true,
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{"{", "#", "^", "/", "&", "."},
+			},
+		},
+		"serverInfo": map[string]string{"name": "mustache-lsp"},
+	}, nil
+}
+
+// path returns name relative to the root of the workspace.
+func (s *server) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.root, name)
+}
+
+// partial returns the file of the partial named name included by the
+// template at uri.
+func (s *server) partial(uri, name string) string {
+	dir := filepath.Dir(uriPath(uri))
+	if s.options.Partials != "" {
+		dir = s.path(s.options.Partials)
+	}
+	return filepath.Join(dir, name+".mustache")
+}
+
+// publish sends the diagnostics of the document at uri.
+func (s *server) publish(uri string) error {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil
+	}
+	file := uriPath(uri)
+	config := &lint.Config{
+		HTML: strings.Contains(filepath.Base(file), ".html"),
+		Partial: func(name string) bool {
+			_, err := os.Stat(s.partial(uri, name))
+			return err == nil
+		},
+	}
+	diagnostics := []diagnostic{}
+	for _, l := range lint.Lint(file, []byte(d.text), config) {
+		severity := severityWarning
+		switch l.Rule {
+		case lint.Syntax, lint.Unclosed, lint.Mismatch:
+			severity = severityError
+		}
+		var p position
+		if l.Pos.IsValid() {
+			p = d.position(d.runeOffset(l.Pos.Line, l.Pos.Col))
+		}
+		end := p
+		end.Character++
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    lspRange{p, end},
+			Severity: severity,
+			Source:   "mustache",
+			Message:  l.Message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// definition returns the location of the partial included by the tag at the
+// position of p.
+func (s *server) definition(p textDocumentPositionParams) []location {
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	i := d.offset(p.Position)
+	for _, t := range scanTags(d.text) {
+		if t.sigil == '>' && t.start <= i && i <= t.end {
+			file := s.partial(p.TextDocument.URI, t.name)
+			if _, err := os.Stat(file); err != nil {
+				return nil
+			}
+			return []location{{URI: pathURI(file)}}
+		}
+	}
+	return nil
+}
+
+// symbols returns the sections of the document at uri, nested as they are
+// in the template.
+func (s *server) symbols(uri string) []documentSymbol {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil
+	}
+	type open struct {
+		tag
+		children []documentSymbol
+	}
+	stack := []open{{}}
+	for _, t := range scanTags(d.text) {
+		switch t.sigil {
+		case '#', '^':
+			stack = append(stack, open{tag: t})
+		case '/':
+			n := len(stack) - 1
+			if n == 0 || stack[n].name != t.name {
+				// Mismatched sections are reported as diagnostics.
+				continue
+			}
+			o := stack[n]
+			stack = stack[:n]
+			stack[n-1].children = append(stack[n-1].children, documentSymbol{
+				Name:           string(o.sigil) + o.name,
+				Kind:           symbolKindNamespace,
+				Range:          d.span(o.start, t.end),
+				SelectionRange: d.span(o.start, o.end),
+				Children:       o.children,
+			})
+		}
+	}
+	if stack[0].children == nil {
+		return []documentSymbol{}
+	}
+	return stack[0].children
+}
+
+// completion returns the names which may be used in the tag at the position
+// of p.
+func (s *server) completion(p textDocumentPositionParams) []completionItem {
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	var (
+		i        = d.offset(p.Position)
+		sections []tag
+		current  *tag
+	)
+	for _, t := range scanTags(d.text) {
+		if t.start >= i {
+			break
+		}
+		if !t.closed || t.end > i {
+			current = &t
+			break
+		}
+		switch t.sigil {
+		case '#', '^':
+			sections = append(sections, t)
+		case '/':
+			if n := len(sections); n > 0 && sections[n-1].name == t.name {
+				sections = sections[:n-1]
+			}
+		}
+	}
+	items := []completionItem{}
+	if current == nil || strings.IndexByte("!=>", current.sigil) >= 0 {
+		return items
+	}
+	prefix := strings.TrimLeft(d.text[current.start:i], "{#^/&!=>")
+	prefix = strings.TrimSpace(prefix)
+
+	var names []string
+	if s.data == nil {
+		names = s.used(d.text)
+	} else {
+		chain := []shape{s.data}
+		for _, t := range sections {
+			if t.sigil == '^' {
+				continue // inverted sections don't push a context
+			}
+			if v := resolve(t.name, chain); v != nil {
+				chain = append([]shape{v.section()}, chain...)
+			}
+		}
+		if j := strings.LastIndexByte(prefix, '.'); j >= 0 {
+			if v := resolve(prefix[:j], chain); v != nil {
+				names = v.names()
+			}
+		} else {
+			seen := make(map[string]bool)
+			for _, c := range chain {
+				for _, name := range c.names() {
+					if !seen[name] {
+						seen[name] = true
+						names = append(names, name)
+					}
+				}
+			}
+			sort.Strings(names)
+		}
+	}
+	kind := completionKindField
+	if s.data == nil {
+		kind = completionKindVariable
+	}
+	for _, name := range names {
+		items = append(items, completionItem{Label: name, Kind: kind})
+	}
+	return items
+}
+
+// used returns the names used by the variable and section tags of text.
+func (s *server) used(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, t := range scanTags(text) {
+		if !t.closed || t.name == "" || t.name == "." || strings.IndexByte("!=>/", t.sigil) >= 0 {
+			continue
+		}
+		if !seen[t.name] {
+			seen[t.name] = true
+			names = append(names, t.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// uriPath returns the file path of a file:// URI.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathURI returns the file:// URI of a file path.
+func pathURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
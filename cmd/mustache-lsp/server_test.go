@@ -0,0 +1,181 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// session runs the server with the given requests and returns the messages it
+// wrote, in order.
+func session(t *testing.T, requests ...interface{}) []*message {
+	var in, out bytes.Buffer
+	for i, r := range requests {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := new(message)
+		if err := json.Unmarshal(b, m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Method != "textDocument/didOpen" && m.Method != "textDocument/didChange" {
+			id := json.RawMessage(strconv.Itoa(i))
+			m.ID = &id
+		}
+		if err := writeMessage(&in, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := newServer(&in, &out).serve(); err != nil {
+		t.Fatal(err)
+	}
+	var messages []*message
+	r := bufio.NewReader(&out)
+	for r.Buffered() > 0 || out.Len() > 0 {
+		m, err := readMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+type request struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+func open(uri, text string) request {
+	return request{"textDocument/didOpen", didOpenParams{textDocumentItem{uri, text}}}
+}
+
+func at(method, uri string, line, char int) request {
+	return request{method, textDocumentPositionParams{textDocumentIdentifier{uri}, position{line, char}}}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := `{"title": "x", "items": [{"name": "a", "price": 1}], "user": {"email": "e"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "data.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "footer.mustache"), []byte("bye"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := pathURI(filepath.Join(dir, "page.mustache"))
+	text := "{{title}}\n{{#items}}\n  {{na}} {{user.}}\n{{/items}}\n{{>footer}}"
+
+	messages := session(t,
+		request{"initialize", initializeParams{
+			RootURI:               pathURI(dir),
+			InitializationOptions: initializeOptions{Data: "data.json"},
+		}},
+		open(uri, text),
+		at("textDocument/completion", uri, 2, 6),
+		at("textDocument/completion", uri, 2, 16),
+		at("textDocument/definition", uri, 4, 4),
+		request{"textDocument/documentSymbol", didCloseParams{textDocumentIdentifier{uri}}},
+		open(uri, "{{#a}}\n{{/b}}"),
+		request{"shutdown", nil},
+	)
+	if len(messages) != 8 {
+		t.Fatalf("expected 8 messages, got %d", len(messages))
+	}
+
+	var diagnostics publishDiagnosticsParams
+	json.Unmarshal(messages[1].Params, &diagnostics)
+	if len(diagnostics.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics %+v", diagnostics.Diagnostics)
+	}
+
+	labels := func(m *message) []string {
+		var items []completionItem
+		json.Unmarshal(m.Result, &items)
+		var l []string
+		for _, item := range items {
+			l = append(l, item.Label)
+		}
+		return l
+	}
+	if l, expected := labels(messages[2]), []string{"items", "name", "price", "title", "user"}; !reflect.DeepEqual(l, expected) {
+		t.Errorf("expected completion %v, got %v", expected, l)
+	}
+	if l, expected := labels(messages[3]), []string{"email"}; !reflect.DeepEqual(l, expected) {
+		t.Errorf("expected completion %v, got %v", expected, l)
+	}
+
+	var locations []location
+	json.Unmarshal(messages[4].Result, &locations)
+	if len(locations) != 1 || locations[0].URI != pathURI(filepath.Join(dir, "footer.mustache")) {
+		t.Errorf("unexpected definition %+v", locations)
+	}
+
+	var symbols []documentSymbol
+	json.Unmarshal(messages[5].Result, &symbols)
+	expected := []documentSymbol{{
+		Name:           "#items",
+		Kind:           symbolKindNamespace,
+		Range:          lspRange{position{1, 0}, position{3, 10}},
+		SelectionRange: lspRange{position{1, 0}, position{1, 10}},
+	}}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("expected symbols %+v, got %+v", expected, symbols)
+	}
+
+	json.Unmarshal(messages[6].Params, &diagnostics)
+	if len(diagnostics.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diagnostics.Diagnostics)
+	}
+	if d := diagnostics.Diagnostics[0]; d.Range.Start != (position{1, 0}) || d.Severity != severityError {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+}
+
+func TestServerUTF16(t *testing.T) {
+	uri := "file:///page.mustache"
+	messages := session(t,
+		open(uri, "😀 {{#a}}{{/b}}"),
+		request{"shutdown", nil},
+	)
+	var diagnostics publishDiagnosticsParams
+	json.Unmarshal(messages[0].Params, &diagnostics)
+	if len(diagnostics.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diagnostics.Diagnostics)
+	}
+	// The emoji is encoded by two UTF-16 code units.
+	if r := diagnostics.Diagnostics[0].Range; r.Start != (position{0, 9}) || r.End != (position{0, 10}) {
+		t.Errorf("unexpected range %+v", r)
+	}
+	d := newDocument("😀 {{a}}\nx")
+	if p := d.position(len("😀 {{")); p != (position{0, 5}) {
+		t.Errorf("unexpected position %+v", p)
+	}
+	if i := d.offset(position{0, 5}); i != len("😀 {{") {
+		t.Errorf("unexpected offset %d", i)
+	}
+}
+
+func TestServerParseError(t *testing.T) {
+	var in, out bytes.Buffer
+	in.WriteString("Content-Length: 5\r\n\r\n{oops")
+	if err := newServer(&in, &out).serve(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`"id":null`)) {
+		t.Errorf("expected a null id in %q", out.String())
+	}
+}
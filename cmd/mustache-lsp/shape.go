@@ -0,0 +1,181 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alexkappa/mustache/cmd/internal/load"
+)
+
+// The shape interface describes the data a template is rendered with, as far
+// as completion is concerned.
+type shape interface {
+	// names returns the names which can be looked up in a context of this
+	// shape.
+	names() []string
+	// member returns the shape of the value of name, or nil if there is no
+	// such name.
+	member(name string) shape
+	// section returns the shape of the context pushed by a section whose
+	// value has this shape.
+	section() shape
+}
+
+// resolve returns the shape of the value of name in chain, the innermost
+// context first, following dotted names.
+func resolve(name string, chain []shape) shape {
+	parts := strings.Split(name, ".")
+	for _, s := range chain {
+		if v := s.member(parts[0]); v != nil {
+			for _, part := range parts[1:] {
+				if v = v.member(part); v == nil {
+					return nil
+				}
+			}
+			return v
+		}
+	}
+	return nil
+}
+
+// The jsonShape type is the shape of sample data decoded from JSON.
+type jsonShape struct {
+	v interface{}
+}
+
+func loadJSON(file string) (shape, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return jsonShape{v}, nil
+}
+
+func (s jsonShape) names() []string {
+	m, ok := s.v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s jsonShape) member(name string) shape {
+	m, ok := s.v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	v, ok := m[name]
+	if !ok {
+		return nil
+	}
+	return jsonShape{v}
+}
+
+func (s jsonShape) section() shape {
+	if a, ok := s.v.([]interface{}); ok && len(a) > 0 {
+		return jsonShape{a[0]}
+	}
+	return s
+}
+
+// The goShape type is the shape of a Go type.
+type goShape struct {
+	t types.Type
+}
+
+func (s goShape) names() []string {
+	t := deref(s.t)
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if tag := reflect.StructTag(st.Tag(i)).Get("template"); tag != "" {
+			names = append(names, tag)
+		}
+		if f.Exported() {
+			names = append(names, f.Name())
+		}
+	}
+	methods := types.NewMethodSet(t)
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.At(i).Obj()
+		sig := m.Type().(*types.Signature)
+		if m.Exported() && sig.Params().Len() == 0 && sig.Results().Len() > 0 {
+			names = append(names, m.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s goShape) member(name string) shape {
+	t := deref(s.t)
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(t, false, nil, name)
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.Exported() {
+			return goShape{obj.Type()}
+		}
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if obj.Exported() && sig.Params().Len() == 0 && sig.Results().Len() > 0 {
+			return goShape{sig.Results().At(0).Type()}
+		}
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if reflect.StructTag(st.Tag(i)).Get("template") == name {
+			return goShape{st.Field(i).Type()}
+		}
+	}
+	return nil
+}
+
+func (s goShape) section() shape {
+	switch u := deref(s.t).Underlying().(type) {
+	case *types.Slice:
+		return goShape{u.Elem()}
+	case *types.Array:
+		return goShape{u.Elem()}
+	}
+	return s
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// loadGoType type checks the package in dir and returns the shape of its type
+// named name.
+func loadGoType(dir, name string) (shape, error) {
+	typ, err := load.Type(dir, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return goShape{typ}, nil
+}
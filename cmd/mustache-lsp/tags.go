@@ -0,0 +1,134 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// The tag type is a tag found by scanTags.
+type tag struct {
+	sigil  byte   // first character of the tag, such as '#', or 0 for variables
+	name   string // name of the tag, without its sigil and surrounding spaces
+	start  int    // offset of the left delimiter
+	end    int    // offset following the right delimiter, or the end of text
+	closed bool   // the tag is terminated by a right delimiter
+}
+
+// scanTags returns the tags of text. Unlike the parser it never fails, so that
+// templates can be inspected while being edited.
+func scanTags(text string) []tag {
+	var (
+		tags        []tag
+		left, right = "{{", "}}"
+		i           = 0
+	)
+	for {
+		j := strings.Index(text[i:], left)
+		if j < 0 {
+			return tags
+		}
+		t := tag{start: i + j}
+		i = t.start + len(left)
+		if i < len(text) && strings.IndexByte("#^/>&!={", text[i]) >= 0 {
+			t.sigil = text[i]
+			i++
+		}
+		closing := right
+		switch t.sigil {
+		case '{':
+			closing = "}" + right
+		case '=':
+			closing = "=" + right
+		}
+		k := strings.Index(text[i:], closing)
+		if k < 0 {
+			t.name = strings.TrimSpace(text[i:])
+			t.end = len(text)
+			return append(tags, t)
+		}
+		t.name = strings.TrimSpace(text[i : i+k])
+		t.end = i + k + len(closing)
+		t.closed = true
+		i = t.end
+		if t.sigil == '=' {
+			if d := strings.Fields(t.name); len(d) == 2 {
+				left, right = d[0], d[1]
+			}
+		}
+		tags = append(tags, t)
+	}
+}
+
+// The document type is the text of an open template.
+type document struct {
+	text  string
+	lines []int // offsets at which each line starts
+}
+
+func newDocument(text string) *document {
+	d := &document{text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+// position returns the position of offset i. Positions count characters in
+// UTF-16 code units, as LSP clients do by default, so characters outside of
+// the Basic Multilingual Plane, such as most emoji, count as two.
+func (d *document) position(i int) position {
+	line := len(d.lines) - 1
+	for line > 0 && d.lines[line] > i {
+		line--
+	}
+	n := 0
+	for _, r := range d.text[d.lines[line]:i] {
+		n += utf16Len(r)
+	}
+	return position{line, n}
+}
+
+// offset returns the offset of position p, whose character is counted in
+// UTF-16 code units.
+func (d *document) offset(p position) int {
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	i := d.lines[p.Line]
+	for n := 0; n < p.Character && i < len(d.text) && d.text[i] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[i:])
+		n += utf16Len(r)
+		i += size
+	}
+	return i
+}
+
+// runeOffset returns the offset of the character at column col, counted in
+// runes from 1, of line, counted from 1, as in the positions of the parser.
+func (d *document) runeOffset(line, col int) int {
+	if line < 1 || line > len(d.lines) {
+		return len(d.text)
+	}
+	i := d.lines[line-1]
+	for n := 1; n < col && i < len(d.text) && d.text[i] != '\n'; n++ {
+		_, size := utf8.DecodeRuneInString(d.text[i:])
+		i += size
+	}
+	return i
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) span(start, end int) lspRange {
+	return lspRange{d.position(start), d.position(end)}
+}
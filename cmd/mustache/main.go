@@ -0,0 +1,177 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Command mustache renders a mustache template to the standard output.
+//
+// Usage:
+//
+//	mustache [flags] template.mustache
+//
+// The data used as context is read from the file given by the -data flag, or
+// from the standard input if the flag is "-". It is decoded as JSON, YAML or
+// TOML depending on the -format flag or, if unset, on the file's extension.
+// Only a simple subset of YAML and TOML is supported.
+//
+// Partials are read from the *.mustache files of the -partials directory and
+// are named after their base name without the extension.
+//
+// With the -schema flag, the template isn't rendered. A JSON Schema describing
+// the data expected by the template is printed instead.
+//
+// The exit code is 1 for I/O errors, including errors reading the data, 2 for
+// invalid usage, 3 if the template or a partial failed to parse and 4 if the
+// -strict flag is set and a variable lookup failed when rendering.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexkappa/mustache"
+	"github.com/alexkappa/mustache/cmd/internal/load"
+)
+
+// Exit codes.
+const (
+	exitIO     = 1
+	exitUsage  = 2
+	exitParse  = 3
+	exitRender = 4
+)
+
+var (
+	data     = flag.String("data", "", "data file used as context; \"-\" reads from standard input")
+	format   = flag.String("format", "", "data format, one of json, yaml or toml; default by extension or json")
+	partials = flag.String("partials", "", "directory of *.mustache files used as partials")
+	delims   = flag.String("delims", "", "start and end delimiters separated by a space, such as \"<% %>\"")
+	strict   = flag.Bool("strict", false, "fail if a variable lookup fails")
+	schema   = flag.Bool("schema", false, "print a JSON Schema of the data expected by the template instead of rendering it")
+)
+
+// The exitError type associates an error with an exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: mustache [flags] template.mustache\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "mustache: %s\n", err)
+		if e, ok := err.(*exitError); ok {
+			os.Exit(e.code)
+		}
+		os.Exit(exitIO)
+	}
+}
+
+func run(name string) error {
+	options := []mustache.Option{mustache.SilentMiss(!*strict)}
+	if *delims != "" {
+		d := strings.Fields(*delims)
+		if len(d) != 2 {
+			return &exitError{exitUsage, fmt.Errorf("invalid delimiters %q", *delims)}
+		}
+		options = append(options, mustache.Delimiters(d[0], d[1]))
+	}
+	t, err := load.Template(name, options...)
+	if err != nil {
+		return parseError(err)
+	}
+	if *partials != "" {
+		if err := load.Partials(t, *partials, options...); err != nil {
+			return parseError(err)
+		}
+	}
+	if *schema {
+		b, err := t.JSONSchema()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Printf("%s\n", b)
+		return err
+	}
+	context, err := readData(*data, *format)
+	if err != nil {
+		return err
+	}
+	// Render to a buffer first, so that errors writing the output are not
+	// mistaken for errors rendering the template.
+	var b bytes.Buffer
+	if err := t.Render(&b, context); err != nil {
+		return &exitError{exitRender, err}
+	}
+	_, err = os.Stdout.Write(b.Bytes())
+	return err
+}
+
+// parseError returns err with the exit code of parse errors if it is one.
+func parseError(err error) error {
+	var pe *mustache.ParseError
+	if errors.As(err, &pe) {
+		return &exitError{exitParse, err}
+	}
+	return err
+}
+
+// readData reads and decodes the data in file. If file is empty, there is no
+// data and nil is returned.
+func readData(file, format string) (interface{}, error) {
+	var (
+		b   []byte
+		err error
+	)
+	switch file {
+	case "":
+		return nil, nil
+	case "-":
+		b, err = ioutil.ReadAll(os.Stdin)
+	default:
+		b, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+	var v interface{}
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		v, err = decodeYAML(b)
+	case "toml":
+		v, err = decodeTOML(b)
+	case "json", "":
+		// Numbers are decoded as written, rather than as float64 values which
+		// would format large integers in exponent notation.
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		err = d.Decode(&v)
+	default:
+		return nil, &exitError{exitUsage, fmt.Errorf("unknown data format %q", format)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return v, nil
+}
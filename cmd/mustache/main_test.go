@@ -0,0 +1,89 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "page.mustache")
+	if err := ioutil.WriteFile(template, []byte("{{id}} {{price}} {{name}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		file     string
+		data     string
+		expected string
+	}{
+		{"data.json", `{"id": 1234567, "price": 12.5, "name": "pen"}`, "1234567 12.5 pen"},
+		{"data.toml", "id = 1234567\nprice = 12.5\nname = \"pen\"", "1234567 12.5 pen"},
+	} {
+		file := filepath.Join(dir, test.file)
+		if err := ioutil.WriteFile(file, []byte(test.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		*data = file
+		output, err := capture(func() error { return run(template) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected %q got %q", test.file, test.expected, output)
+		}
+	}
+	*data = ""
+}
+
+// capture returns what fn writes to the standard output.
+func capture(fn func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = fn()
+	os.Stdout = stdout
+	w.Close()
+	b, _ := ioutil.ReadAll(r)
+	return string(b), err
+}
+
+func TestRunSchema(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "page.mustache")
+	if err := ioutil.WriteFile(template, []byte("{{title}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	*schema = true
+	defer func() { *schema = false }()
+	output, err := capture(func() error { return run(template) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"title"`) {
+		t.Errorf("expected a schema of title, got %q", output)
+	}
+}
+
+func TestRunParseError(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "page.mustache")
+	if err := ioutil.WriteFile(template, []byte("{{>item}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "item.mustache"), []byte("{{#a}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	*partials = dir
+	defer func() { *partials = "" }()
+	err := run(template)
+	if e, ok := err.(*exitError); !ok || e.code != exitParse {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// decodeTOML decodes a simple subset of TOML made up of key/value pairs,
+// tables, arrays of tables, dotted keys, basic and literal strings, numbers,
+// booleans, arrays and inline tables. Dates and times are decoded as strings.
+// Tables are decoded as map[string]interface{} and arrays as []interface{},
+// the same way encoding/json does.
+func decodeTOML(b []byte) (interface{}, error) {
+	root := make(map[string]interface{})
+	current := root
+	lines := strings.Split(string(b), "\n")
+	for i := 0; i < len(lines); i++ {
+		num := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("line %d: invalid table %q", num, line)
+			}
+			keys, err := tomlKeys(line[2 : len(line)-2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", num, err)
+			}
+			parent, err := tomlTable(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", num, err)
+			}
+			last := keys[len(keys)-1]
+			array, ok := parent[last].([]interface{})
+			if !ok && parent[last] != nil {
+				return nil, fmt.Errorf("line %d: %s is not an array of tables", num, last)
+			}
+			current = make(map[string]interface{})
+			parent[last] = append(array, current)
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table %q", num, line)
+			}
+			keys, err := tomlKeys(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", num, err)
+			}
+			if current, err = tomlTable(root, keys); err != nil {
+				return nil, fmt.Errorf("line %d: %s", num, err)
+			}
+		default:
+			// Arrays and inline tables may span several lines.
+			for !balanced(line) && i+1 < len(lines) {
+				i++
+				line += "\n" + strings.TrimSpace(stripComment(lines[i]))
+			}
+			if err := tomlPair(current, line); err != nil {
+				return nil, fmt.Errorf("line %d: %s", num, err)
+			}
+		}
+	}
+	return root, nil
+}
+
+// tomlPair decodes a "key = value" pair into table.
+func tomlPair(table map[string]interface{}, s string) error {
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		return fmt.Errorf("expected a key/value pair in %q", s)
+	}
+	keys, err := tomlKeys(s[:i])
+	if err != nil {
+		return err
+	}
+	v, rest, err := tomlValue(strings.TrimSpace(s[i+1:]))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return fmt.Errorf("unexpected %q", rest)
+	}
+	t, err := tomlTable(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	t[keys[len(keys)-1]] = v
+	return nil
+}
+
+// tomlTable returns the table named by keys in root, creating it if needed.
+// Keys naming an array of tables refer to its last table.
+func tomlTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	t := root
+	for _, key := range keys {
+		switch v := t[key].(type) {
+		case nil:
+			m := make(map[string]interface{})
+			t[key] = m
+			t = m
+		case map[string]interface{}:
+			t = v
+		case []interface{}:
+			if len(v) == 0 {
+				return nil, fmt.Errorf("%s is not a table", key)
+			}
+			m, ok := v[len(v)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", key)
+			}
+			t = m
+		default:
+			return nil, fmt.Errorf("%s is not a table", key)
+		}
+	}
+	return t, nil
+}
+
+// tomlKeys splits a possibly dotted and quoted key.
+func tomlKeys(s string) ([]string, error) {
+	var keys []string
+	s = strings.TrimSpace(s)
+	for {
+		var key string
+		switch {
+		case strings.HasPrefix(s, `"`), strings.HasPrefix(s, "'"):
+			v, rest, err := tomlString(s)
+			if err != nil {
+				return nil, err
+			}
+			key, s = v, rest
+		default:
+			i := strings.IndexAny(s, ". \t")
+			if i < 0 {
+				i = len(s)
+			}
+			key, s = s[:i], s[i:]
+		}
+		if key == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+		keys = append(keys, key)
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return keys, nil
+		}
+		if s[0] != '.' {
+			return nil, fmt.Errorf("invalid key %q", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+// tomlValue decodes the value at the start of s and returns the remaining
+// input.
+func tomlValue(s string) (interface{}, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch s[0] {
+	case '"', '\'':
+		return tomlString(s)
+	case '[':
+		return tomlArray(s)
+	case '{':
+		return tomlInline(s)
+	}
+	i := strings.IndexAny(s, ",]}\n")
+	if i < 0 {
+		i = len(s)
+	}
+	v, rest := strings.TrimSpace(s[:i]), s[i:]
+	switch v {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	case "inf", "+inf":
+		return math.Inf(1), rest, nil
+	case "-inf":
+		return math.Inf(-1), rest, nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), rest, nil
+	}
+	n := strings.Replace(v, "_", "", -1)
+	if i, err := strconv.ParseInt(n, 0, 64); err == nil {
+		return i, rest, nil
+	}
+	if f, err := strconv.ParseFloat(n, 64); err == nil {
+		return f, rest, nil
+	}
+	if v != "" && v[0] >= '0' && v[0] <= '9' {
+		// Dates and times are kept as strings.
+		return v, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value %q", v)
+}
+
+// tomlString decodes the basic or literal string at the start of s.
+func tomlString(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''") {
+		return "", "", fmt.Errorf("multi-line strings are not supported")
+	}
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			v, err := strconv.Unquote(s[:i+1])
+			return v, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// tomlArray decodes the array at the start of s.
+func tomlArray(s string) (interface{}, string, error) {
+	a := []interface{}{}
+	s = strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(s, "]") {
+		v, rest, err := tomlValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		a = append(a, v)
+		s = strings.TrimSpace(rest)
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+	}
+	return a, s[1:], nil
+}
+
+// tomlInline decodes the inline table at the start of s.
+func tomlInline(s string) (interface{}, string, error) {
+	t := make(map[string]interface{})
+	s = strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(s, "}") {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return nil, "", fmt.Errorf("unterminated inline table")
+		}
+		keys, err := tomlKeys(s[:i])
+		if err != nil {
+			return nil, "", err
+		}
+		v, rest, err := tomlValue(strings.TrimSpace(s[i+1:]))
+		if err != nil {
+			return nil, "", err
+		}
+		table, err := tomlTable(t, keys[:len(keys)-1])
+		if err != nil {
+			return nil, "", err
+		}
+		table[keys[len(keys)-1]] = v
+		s = strings.TrimSpace(rest)
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if !strings.HasPrefix(s, "}") {
+			return nil, "", fmt.Errorf("unterminated inline table")
+		}
+	}
+	return t, s[1:], nil
+}
+
+// balanced reports whether the brackets and braces of s, outside of strings,
+// are balanced.
+func balanced(s string) bool {
+	var (
+		depth int
+		quote byte
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	input := `# invoice
+title = "Invoice #1" # a comment
+count = 1_000
+ratio = 0.25
+paid = false
+date = 2014-06-01
+"quoted key" = 'C:\path'
+customer.name = "Alex"
+tags = [
+  "a", # first
+  "b",
+]
+
+[address]
+city = "Athens"
+geo = { lat = 37.9, lng = 23.7 }
+
+[[items]]
+name = "one"
+
+[[items]]
+name = "two"
+[items.meta]
+color = "red"
+`
+	expected := map[string]interface{}{
+		"title":      "Invoice #1",
+		"count":      int64(1000),
+		"ratio":      0.25,
+		"paid":       false,
+		"date":       "2014-06-01",
+		"quoted key": `C:\path`,
+		"customer":   map[string]interface{}{"name": "Alex"},
+		"tags":       []interface{}{"a", "b"},
+		"address": map[string]interface{}{
+			"city": "Athens",
+			"geo":  map[string]interface{}{"lat": 37.9, "lng": 23.7},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"name": "one"},
+			map[string]interface{}{
+				"name": "two",
+				"meta": map[string]interface{}{"color": "red"},
+			},
+		},
+	}
+	v, err := decodeTOML([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("unexpected value %#v", v)
+	}
+}
+
+func TestDecodeTOMLError(t *testing.T) {
+	for _, input := range []string{
+		"a = ",
+		"a = [1, 2",
+		"a = 1\n[a]\nb = 2",
+		"a = []\n[a.b]\nc = 1",
+		"[table",
+		"a = '''multi'''",
+	} {
+		if _, err := decodeTOML([]byte(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
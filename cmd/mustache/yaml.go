@@ -0,0 +1,310 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// decodeYAML decodes a simple subset of YAML made up of block mappings, block
+// sequences, flow sequences and mappings of scalars, plain and quoted scalars
+// and comments. Mappings are decoded as map[string]interface{} and sequences
+// as []interface{}, the same way encoding/json does.
+func decodeYAML(b []byte) (interface{}, error) {
+	var lines []yamlLine
+	for i, text := range strings.Split(string(b), "\n") {
+		text = strings.TrimRight(stripComment(text), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed as indentation", i+1)
+		}
+		lines = append(lines, yamlLine{i + 1, len(text) - len(trimmed), trimmed})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	d := &yamlDecoder{lines: lines}
+	v, err := d.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos < len(d.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", d.lines[d.pos].num)
+	}
+	return v, nil
+}
+
+// The yamlLine type is a non empty line of a YAML document.
+type yamlLine struct {
+	num    int    // line number
+	indent int    // number of leading spaces
+	text   string // text without indentation and comments
+}
+
+// The yamlDecoder type holds the state of decodeYAML.
+type yamlDecoder struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block decodes a mapping or a sequence whose entries are indented by indent.
+func (d *yamlDecoder) block(indent int) (interface{}, error) {
+	if isSequenceItem(d.lines[d.pos].text) {
+		return d.sequence(indent)
+	}
+	if _, _, ok := splitKey(d.lines[d.pos].text); ok {
+		return d.mapping(indent)
+	}
+	line := d.lines[d.pos]
+	d.pos++
+	return yamlScalar(line.text)
+}
+
+func (d *yamlDecoder) sequence(indent int) (interface{}, error) {
+	s := []interface{}{}
+	for d.pos < len(d.lines) {
+		line := d.lines[d.pos]
+		if line.indent != indent || !isSequenceItem(line.text) {
+			break
+		}
+		item := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if item == "" {
+			// The item is a block on the following lines.
+			d.pos++
+			v, err := d.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+			continue
+		}
+		// The item starts on the same line, so it is decoded as if it
+		// were indented past the dash.
+		d.lines[d.pos] = yamlLine{line.num, line.indent + len(line.text) - len(item), item}
+		v, err := d.block(d.lines[d.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+func (d *yamlDecoder) mapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for d.pos < len(d.lines) {
+		line := d.lines[d.pos]
+		if line.indent != indent {
+			break
+		}
+		key, value, ok := splitKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a mapping key", line.num)
+		}
+		d.pos++
+		if value != "" {
+			v, err := yamlScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line.num, err)
+			}
+			m[key] = v
+			continue
+		}
+		v, err := d.nested(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// nested decodes the block following a mapping key or sequence dash found at
+// indent. Sequences are allowed at the same indentation as their key.
+func (d *yamlDecoder) nested(indent int) (interface{}, error) {
+	if d.pos >= len(d.lines) {
+		return nil, nil
+	}
+	next := d.lines[d.pos]
+	if next.indent > indent || (next.indent == indent && isSequenceItem(next.text)) {
+		return d.block(next.indent)
+	}
+	return nil, nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitKey splits a "key: value" line. Keys may be quoted.
+func splitKey(text string) (key, value string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		k, err := yamlScalar(text[:end+2])
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != ":" && !strings.HasPrefix(rest, ": ") {
+			return "", "", false
+		}
+		return fmt.Sprint(k), strings.TrimSpace(rest[1:]), true
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if strings.HasSuffix(text, ":") {
+			return text[:len(text)-1], "", true
+		}
+		return "", "", false
+	}
+	if strings.ContainsAny(text[:i], "[{") {
+		return "", "", false
+	}
+	return text[:i], strings.TrimSpace(text[i+1:]), true
+}
+
+// stripComment removes a comment from a line, ignoring "#" inside quotes.
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// yamlScalar decodes a scalar or a flow sequence or mapping.
+func yamlScalar(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		v, rest, err := yamlFlow(s)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+		return v, nil
+	}
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
+
+// yamlFlow decodes a flow sequence or mapping at the start of s and returns
+// the remaining input.
+func yamlFlow(s string) (interface{}, string, error) {
+	end := byte(']')
+	if s[0] == '{' {
+		end = '}'
+	}
+	var (
+		seq = []interface{}{}
+		m   = make(map[string]interface{})
+	)
+	s = strings.TrimLeft(s[1:], " ")
+	for {
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated flow collection")
+		}
+		if s[0] == end {
+			break
+		}
+		var (
+			key  string
+			item interface{}
+			err  error
+		)
+		if end == '}' {
+			i := strings.IndexByte(s, ':')
+			if i < 0 {
+				return nil, "", fmt.Errorf("expected a mapping key in %q", s)
+			}
+			k, err := yamlScalar(strings.TrimSpace(s[:i]))
+			if err != nil {
+				return nil, "", err
+			}
+			key = fmt.Sprint(k)
+			s = strings.TrimLeft(s[i+1:], " ")
+		}
+		if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+			item, s, err = yamlFlow(s)
+		} else {
+			i := flowItemEnd(s, end)
+			item, err = yamlScalar(strings.TrimSpace(s[:i]))
+			s = s[i:]
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if end == '}' {
+			m[key] = item
+		} else {
+			seq = append(seq, item)
+		}
+		s = strings.TrimLeft(s, " ")
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimLeft(s[1:], " ")
+		}
+	}
+	if end == '}' {
+		return m, s[1:], nil
+	}
+	return seq, s[1:], nil
+}
+
+// flowItemEnd returns the index of the end of the flow item at the start of s.
+func flowItemEnd(s string, end byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',' || c == end:
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"title: Hello # a comment\ncount: 3\nratio: 0.5\nok: true\nnothing: ~\nquoted: \"a # b\"\nsingle: 'it''s'",
+			map[string]interface{}{
+				"title":   "Hello",
+				"count":   int64(3),
+				"ratio":   0.5,
+				"ok":      true,
+				"nothing": nil,
+				"quoted":  "a # b",
+				"single":  "it's",
+			},
+		},
+		{
+			"---\nuser:\n  name: Alex\n  tags: [a, b, 'c, d']\n  meta: {x: 1, y: [2]}\nitems:\n- name: one\n  price: 1\n- name: two\n-\n  nested: yes\n- plain\n",
+			map[string]interface{}{
+				"user": map[string]interface{}{
+					"name": "Alex",
+					"tags": []interface{}{"a", "b", "c, d"},
+					"meta": map[string]interface{}{"x": int64(1), "y": []interface{}{int64(2)}},
+				},
+				"items": []interface{}{
+					map[string]interface{}{"name": "one", "price": int64(1)},
+					map[string]interface{}{"name": "two"},
+					map[string]interface{}{"nested": "yes"},
+					"plain",
+				},
+			},
+		},
+		{
+			"- - a\n  - b\n- c",
+			[]interface{}{[]interface{}{"a", "b"}, "c"},
+		},
+	} {
+		v, err := decodeYAML([]byte(test.input))
+		if err != nil {
+			t.Fatalf("%q: %s", test.input, err)
+		}
+		if !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("%q: unexpected value %#v", test.input, v)
+		}
+	}
+}
+
+func TestDecodeYAMLError(t *testing.T) {
+	for _, input := range []string{
+		"a: 1\n  b: 2",
+		"a: [1, 2",
+		"a: \"unterminated",
+	} {
+		if _, err := decodeYAML([]byte(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
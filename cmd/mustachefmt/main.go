@@ -0,0 +1,187 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Command mustachefmt formats mustache templates.
+//
+// Without an explicit path, it processes the standard input. Given a file, it
+// operates on that file; given a directory, it operates on all .mustache files
+// in that directory, recursively. By default, mustachefmt prints the formatted
+// templates to the standard output.
+//
+// Usage:
+//
+//	mustachefmt [flags] [path ...]
+//
+// The flags are:
+//
+//	-d
+//		Do not print formatted templates to standard output. If a file's
+//		formatting is different than mustachefmt's, print diffs to
+//		standard output.
+//	-l
+//		Do not print formatted templates to standard output. If a file's
+//		formatting is different from mustachefmt's, print its name to
+//		standard output.
+//	-w
+//		Do not print formatted templates to standard output. If a file's
+//		formatting is different from mustachefmt's, overwrite it with
+//		mustachefmt's version.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/alexkappa/mustache/format"
+)
+
+var (
+	list  = flag.Bool("l", false, "list files whose formatting differs from mustachefmt's")
+	write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	diff  = flag.Bool("d", false, "display diffs instead of rewriting files")
+)
+
+var exitCode = 0
+
+func report(err error) {
+	fmt.Fprintf(os.Stderr, "mustachefmt: %s\n", err)
+	exitCode = 2
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: mustachefmt [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		if *write {
+			report(fmt.Errorf("cannot use -w with standard input"))
+		} else if err := process("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if info.IsDir() {
+			walkDir(path)
+			continue
+		}
+		if err := processFile(path); err != nil {
+			report(err)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func walkDir(dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			report(err)
+			return nil
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".mustache" {
+			if err := processFile(path); err != nil {
+				report(err)
+			}
+		}
+		return nil
+	})
+}
+
+func processFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return process(path, f, os.Stdout)
+}
+
+// process formats the template read from in, named by path, and reports the
+// result according to the flags.
+func process(path string, in io.Reader, out io.Writer) error {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s:%s", path, err)
+	}
+	if !*list && !*write && !*diff {
+		_, err = out.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if *list {
+		fmt.Fprintln(out, path)
+	}
+	if *write {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if *diff {
+		d, err := diffBytes(path, src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %s", err)
+		}
+		out.Write(d)
+	}
+	return nil
+}
+
+// diffBytes returns the unified diff of b1 and b2 using the diff command.
+func diffBytes(path string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTemp(b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTemp(b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+	data, err := exec.Command("diff", "-u", "--label", path+".orig", "--label", path, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		return data, nil
+	}
+	return data, err
+}
+
+func writeTemp(b []byte) (string, error) {
+	f, err := ioutil.TempFile("", "mustachefmt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(b)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Command mustachelint reports suspicious constructs in mustache templates.
+//
+// Usage:
+//
+//	mustachelint [flags] [path ...]
+//
+// Given a file, it checks that file; given a directory, it checks all
+// .mustache files in that directory, recursively. Problems are printed as
+// "file:line:col: message (rule)" and the exit code is 1 if any were found.
+//
+// Templates whose name contains ".html", such as page.html.mustache, are
+// checked for unescaped variables. If the -partials flag is set, partials are
+// checked to exist as .mustache files in that directory.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexkappa/mustache/lint"
+)
+
+var (
+	disable  = flag.String("disable", "", "comma separated list of rules to disable")
+	partials = flag.String("partials", "", "directory of *.mustache files used as partials")
+	html     = flag.Bool("html", false, "check all templates as HTML templates")
+	maxDepth = flag.Int("maxdepth", lint.DefaultMaxDepth, "maximum nesting of sections")
+	rules    = flag.Bool("rules", false, "list the available rules and exit")
+)
+
+var exitCode = 0
+
+func report(err error) {
+	fmt.Fprintf(os.Stderr, "mustachelint: %s\n", err)
+	exitCode = 2
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: mustachelint [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *rules {
+		for _, r := range lint.Rules {
+			fmt.Printf("%-10s %s\n", r.Name, r.Doc)
+		}
+		return
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	config := &lint.Config{
+		Disabled: make(map[string]bool),
+		MaxDepth: *maxDepth,
+	}
+	for _, name := range strings.Split(*disable, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			config.Disabled[name] = true
+		}
+	}
+	if *partials != "" {
+		config.Partial = func(name string) bool {
+			_, err := os.Stat(filepath.Join(*partials, name+".mustache"))
+			return err == nil
+		}
+	}
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if !info.IsDir() {
+			lintFile(path, config)
+			continue
+		}
+		filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				report(err)
+				return nil
+			}
+			if !info.IsDir() && filepath.Ext(path) == ".mustache" {
+				lintFile(path, config)
+			}
+			return nil
+		})
+	}
+	os.Exit(exitCode)
+}
+
+func lintFile(path string, config *lint.Config) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		report(err)
+		return
+	}
+	c := *config
+	c.HTML = *html || strings.Contains(filepath.Base(path), ".html")
+	for _, d := range lint.Lint(path, src, &c) {
+		fmt.Println(d)
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	}
+}
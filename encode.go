@@ -0,0 +1,217 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+// encodingVersion is the version of the encoding produced by MarshalBinary. It
+// is increased whenever the encoding changes, so that templates encoded by an
+// older version are rejected instead of being decoded incorrectly.
+const encodingVersion = 3
+
+// The encodedTemplates type is the encoding of a template and its partials.
+// Templates refer to their partials by their index, so that partials shared by
+// several templates, or including each other, are encoded once.
+type encodedTemplates struct {
+	Version   int
+	Templates []encodedTemplate // the encoded template is the first one
+}
+
+type encodedTemplate struct {
+	Name          string
+	StartDelim    string
+	EndDelim      string
+	SilentMiss    bool
+	Escape        EscapeMode
+	Limits        limits
+	Iteration     bool
+	NegativeIndex bool
+	NilEmpty      bool
+	Filters       bool // whether the filter extension is enabled
+	Locale        string
+	Nodes         []encodedNode
+	Partials      map[string]int
+}
+
+// Kinds of encoded nodes.
+const (
+	encodedText = iota
+	encodedVar
+	encodedSection
+	encodedPartial
+	encodedComment
+	encodedDelim
+)
+
+type encodedNode struct {
+	Kind     int
+	Start    ast.Pos
+	End      ast.Pos
+	Name     string // name of variables, sections and partials
+	Text     string // text of text and comment nodes
+	Escaped  bool
+	Triple   bool
+	Inverted bool
+	Left     string
+	Right    string
+	Filters  []ast.Filter
+	Nodes    []encodedNode
+}
+
+// MarshalBinary satisfies the encoding.BinaryMarshaler interface. The parse
+// tree, options and partials of the template are encoded, so that the template
+// can be restored using UnmarshalBinary without being parsed again. Functions
+// and catalogs set by options, such as the filters of Filters, the policy of
+// Sandbox or the catalog of Translate, can't be encoded and must be set again
+// once the template is restored. Only the built-in filters are restored with
+// the filter extension.
+func (t *Template) MarshalBinary() ([]byte, error) {
+	e := &encodedTemplates{Version: encodingVersion}
+	e.add(t, make(map[*Template]int))
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(e); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// add adds t and its partials to e and returns the index of t.
+func (e *encodedTemplates) add(t *Template, seen map[*Template]int) int {
+	if i, ok := seen[t]; ok {
+		return i
+	}
+	i := len(e.Templates)
+	seen[t] = i
+	e.Templates = append(e.Templates, encodedTemplate{
+		Name:          t.name,
+		StartDelim:    t.startDelim,
+		EndDelim:      t.endDelim,
+		SilentMiss:    t.silentMiss,
+		Escape:        t.escape,
+		Limits:        t.limits,
+		Iteration:     t.iteration,
+		NegativeIndex: t.negativeIndex,
+		NilEmpty:      t.nilEmpty,
+		Filters:       t.filters != nil,
+		Locale:        t.locale,
+		Nodes:         encodeNodes(t.tree),
+		Partials:      make(map[string]int),
+	})
+	for name, p := range t.partials {
+		e.Templates[i].Partials[name] = e.add(p, seen)
+	}
+	return i
+}
+
+func encodeNodes(nodes []ast.Node) []encodedNode {
+	var encoded []encodedNode
+	for _, n := range nodes {
+		var e encodedNode
+		switch n := n.(type) {
+		case *ast.Text:
+			e = encodedNode{Kind: encodedText, Start: n.Start, Text: n.Text}
+		case *ast.Var:
+			e = encodedNode{Kind: encodedVar, Start: n.Start, Name: n.Name, Escaped: n.Escaped, Triple: n.Triple, Filters: n.Filters}
+		case *ast.Section:
+			e = encodedNode{Kind: encodedSection, Start: n.Start, End: n.End, Name: n.Name, Inverted: n.Inverted, Nodes: encodeNodes(n.Nodes)}
+		case *ast.Partial:
+			e = encodedNode{Kind: encodedPartial, Start: n.Start, Name: n.Name}
+		case *ast.Comment:
+			e = encodedNode{Kind: encodedComment, Start: n.Start, Text: n.Text}
+		case *ast.Delim:
+			e = encodedNode{Kind: encodedDelim, Start: n.Start, Left: n.Left, Right: n.Right}
+		}
+		encoded = append(encoded, e)
+	}
+	return encoded
+}
+
+// UnmarshalBinary satisfies the encoding.BinaryUnmarshaler interface. It
+// restores a template encoded by MarshalBinary, replacing the parse tree,
+// options and partials of t.
+func (t *Template) UnmarshalBinary(data []byte) error {
+	var e encodedTemplates
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return err
+	}
+	if e.Version != encodingVersion {
+		return fmt.Errorf("unsupported template encoding version %d", e.Version)
+	}
+	if len(e.Templates) == 0 {
+		return fmt.Errorf("no template found in encoding")
+	}
+	templates := make([]*Template, len(e.Templates))
+	templates[0] = t
+	for i := 1; i < len(templates); i++ {
+		templates[i] = new(Template)
+	}
+	for i, et := range e.Templates {
+		tree, err := decodeNodes(et.Nodes)
+		if err != nil {
+			return err
+		}
+		tt := templates[i]
+		tt.name = et.Name
+		tt.startDelim = et.StartDelim
+		tt.endDelim = et.EndDelim
+		tt.silentMiss = et.SilentMiss
+		tt.escape = et.Escape
+		tt.limits = et.Limits
+		tt.iteration = et.Iteration
+		tt.negativeIndex = et.NegativeIndex
+		tt.nilEmpty = et.NilEmpty
+		if et.Filters {
+			// The built-in filters are restored, the others must be set again.
+			Filters(nil)(tt)
+		}
+		if et.Locale != "" {
+			// The formatters of the locale are restored along with it.
+			Locale(et.Locale)(tt)
+		}
+		tt.tree = tree
+		tt.elems = compile(tree)
+		tt.partials = make(map[string]*Template)
+		for name, j := range et.Partials {
+			if j < 0 || j >= len(templates) {
+				return fmt.Errorf("partial %s refers to unknown template %d", name, j)
+			}
+			tt.partials[name] = templates[j]
+		}
+	}
+	return nil
+}
+
+func decodeNodes(encoded []encodedNode) ([]ast.Node, error) {
+	var nodes []ast.Node
+	for _, e := range encoded {
+		var n ast.Node
+		switch e.Kind {
+		case encodedText:
+			n = &ast.Text{Start: e.Start, Text: e.Text}
+		case encodedVar:
+			n = &ast.Var{Start: e.Start, Name: e.Name, Escaped: e.Escaped, Triple: e.Triple, Filters: e.Filters}
+		case encodedSection:
+			children, err := decodeNodes(e.Nodes)
+			if err != nil {
+				return nil, err
+			}
+			n = &ast.Section{Start: e.Start, End: e.End, Name: e.Name, Inverted: e.Inverted, Nodes: children}
+		case encodedPartial:
+			n = &ast.Partial{Start: e.Start, Name: e.Name}
+		case encodedComment:
+			n = &ast.Comment{Start: e.Start, Text: e.Text}
+		case encodedDelim:
+			n = &ast.Delim{Start: e.Start, Left: e.Left, Right: e.Right}
+		default:
+			return nil, fmt.Errorf("unknown node kind %d", e.Kind)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
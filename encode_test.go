@@ -0,0 +1,137 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	item := New(Name("item"), Delimiters("<%", "%>"))
+	if err := item.ParseString("* <%name%><%#children%>\n<%>list%><%/children%>"); err != nil {
+		t.Fatal(err)
+	}
+	list := New(Name("list"), Partial(item))
+	if err := list.ParseString("{{#items}}{{>item}}\n{{/items}}"); err != nil {
+		t.Fatal(err)
+	}
+	item.Option(Partial(list)) // the partials include each other
+	page := New(Name("page"), SilentMiss(false), Partial(list))
+	if err := page.ParseString("{{! items }}{{title}}\n{{>list}}{{=| |=}}|&title|"); err != nil {
+		t.Fatal(err)
+	}
+	context := map[string]interface{}{
+		"title": "<Items>",
+		"items": []map[string]interface{}{
+			{"name": "a", "children": map[string]interface{}{
+				"items": []map[string]interface{}{{"name": "b"}},
+			}},
+			{"name": "c"},
+		},
+	}
+	b, err := page.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := New()
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Tree(), page.Tree()) {
+		t.Errorf("expected tree %+v, got %+v", page.Tree(), decoded.Tree())
+	}
+	if decoded.silentMiss || decoded.partials["list"].partials["item"].startDelim != "<%" {
+		t.Errorf("options were not decoded")
+	}
+	if p := decoded.partials["list"]; p.partials["item"].partials["list"] != p {
+		t.Errorf("partials including each other were not decoded as such")
+	}
+	expected, err := page.RenderString(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := decoded.RenderString(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	if _, err := decoded.RenderString(map[string]interface{}{}); err == nil {
+		t.Errorf("expected a missed lookup to fail")
+	}
+
+	// Templates are encoded using MarshalBinary when they are encoded by gob.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(map[string]*Template{"page": page}); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]*Template
+	if err := gob.NewDecoder(&buf).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := m["page"].RenderString(context); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	if err := New().UnmarshalBinary([]byte("garbage")); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestMarshalBinaryOptions(t *testing.T) {
+	template := New(
+		Name("list"),
+		Delimiters("<%", "%>"),
+		SilentMiss(false),
+		Escape(EscapeNone),
+		MaxOutput(100),
+		MaxDepth(5),
+		MaxIterations(10),
+		MaxRenderTime(time.Second),
+		Iteration(true),
+		NegativeIndex(true),
+		NilAsEmpty(),
+		Filters(nil),
+		Locale("de-DE"),
+	)
+	if err := template.ParseString("<%#items%><%@index%>:<%.%> <%/items%><%items.-1 | upper%><%none%>"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := template.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := New()
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.name != template.name ||
+		decoded.startDelim != template.startDelim ||
+		decoded.endDelim != template.endDelim ||
+		decoded.silentMiss != template.silentMiss ||
+		decoded.escape != template.escape ||
+		decoded.limits != template.limits ||
+		decoded.iteration != template.iteration ||
+		decoded.negativeIndex != template.negativeIndex ||
+		decoded.nilEmpty != template.nilEmpty ||
+		len(decoded.filters) != len(template.filters) ||
+		decoded.locale != template.locale ||
+		len(decoded.formatters) != len(template.formatters) {
+		t.Errorf("options were not decoded: %+v", decoded)
+	}
+	context := map[string]interface{}{"items": []string{"<a>", "b"}, "none": (*int)(nil)}
+	s, err := decoded.RenderString(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "0:<a> 1:b B"; s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+// The Filter type is a function transforming the value of a variable, such as
+// upper in {{name | upper}}. The value v is nil if the name couldn't be looked
+// up. The arguments following the name of the filter in the tag are passed as
+// args, so {{name | truncate 20}} calls the truncate filter with "20".
+type Filter func(v interface{}, args ...string) (interface{}, error)
+
+// Filters enables the filter extension, in which the value of a variable may
+// be transformed by a chain of filters:
+//
+//	{{name | truncate 20 | upper}}
+//
+// Arguments are separated by spaces and may be quoted, such as
+// {{title | default "Untitled"}}. The filters are available along with the
+// following built-in ones, which may be replaced by filters of the same name:
+//
+//	upper             converts the value to upper case
+//	lower             converts the value to lower case
+//	truncate n [tail] shortens the value to n characters, followed by tail
+//	date layout       formats a time.Time using a layout of the time package
+//	default value     replaces a missing or falsy value
+//	join [sep]        joins the items of a list, separated by ", " or sep
+//
+// Without this option, templates are parsed according to the mustache spec,
+// which doesn't allow filters. The option must be set before parsing.
+func Filters(filters map[string]Filter) Option {
+	return func(t *Template) {
+		if t.filters == nil {
+			t.filters = make(map[string]Filter)
+			for name, f := range builtinFilters {
+				t.filters[name] = f
+			}
+		}
+		for name, f := range filters {
+			t.filters[name] = f
+		}
+	}
+}
+
+var builtinFilters = map[string]Filter{
+	"upper": func(v interface{}, args ...string) (interface{}, error) {
+		return strings.ToUpper(sprint(v)), nil
+	},
+	"lower": func(v interface{}, args ...string) (interface{}, error) {
+		return strings.ToLower(sprint(v)), nil
+	},
+	"truncate": func(v interface{}, args ...string) (interface{}, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("truncate expects a length and an optional tail")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("truncate expects a length, found %q", args[0])
+		}
+		s := []rune(sprint(v))
+		if len(s) <= n {
+			return string(s), nil
+		}
+		if len(args) == 2 {
+			return string(s[:n]) + args[1], nil
+		}
+		return string(s[:n]), nil
+	},
+	"date": func(v interface{}, args ...string) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("date expects a layout")
+		}
+		switch t := v.(type) {
+		case time.Time:
+			return t.Format(args[0]), nil
+		case *time.Time:
+			if t != nil {
+				return t.Format(args[0]), nil
+			}
+		}
+		return nil, fmt.Errorf("date expects a time.Time, found %T", v)
+	},
+	"default": func(v interface{}, args ...string) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("default expects a value")
+		}
+		if v == nil || !truth(reflect.ValueOf(v)) {
+			return args[0], nil
+		}
+		return v, nil
+	},
+	"join": func(v interface{}, args ...string) (interface{}, error) {
+		sep := ", "
+		if len(args) > 1 {
+			return nil, fmt.Errorf("join expects an optional separator")
+		}
+		if len(args) == 1 {
+			sep = args[0]
+		}
+		r := reflect.ValueOf(v)
+		if r.Kind() != reflect.Slice && r.Kind() != reflect.Array {
+			return nil, fmt.Errorf("join expects a list, found %T", v)
+		}
+		s := make([]string, r.Len())
+		for i := range s {
+			s[i] = sprint(r.Index(i).Interface())
+		}
+		return strings.Join(s, sep), nil
+	},
+}
+
+// The sprint function formats v the same way print does.
+func sprint(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	var b strings.Builder
+	print(&b, v)
+	return b.String()
+}
+
+// The filter function applies filters to v, using the filters of template t.
+func filter(t *Template, v interface{}, filters []ast.Filter) (interface{}, error) {
+	for _, f := range filters {
+		fn, ok := t.filters[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %s", f.Name)
+		}
+		var err error
+		v, err = fn(v, f.Args...)
+		if err != nil {
+			return nil, fmt.Errorf("filter %s: %s", f.Name, err)
+		}
+	}
+	return v, nil
+}
+
+// The checkFilters function returns an error for the first filter of the parse
+// tree which isn't known to template t.
+func checkFilters(t *Template, tree []ast.Node) error {
+	var err error
+	ast.Inspect(&ast.Tree{Nodes: tree}, func(n ast.Node) bool {
+		if v, ok := n.(*ast.Var); ok && err == nil {
+			for _, f := range v.Filters {
+				if _, ok := t.filters[f.Name]; !ok {
+					err = &ParseError{v.Start.Line, v.Start.Col, "unknown filter " + f.Name}
+					break
+				}
+			}
+		}
+		return err == nil
+	})
+	return err
+}
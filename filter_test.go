@@ -0,0 +1,85 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	context := map[string]interface{}{
+		"name":  "Gopher <3",
+		"long":  "abcdefghij",
+		"tags":  []string{"a", "b", "c"},
+		"nums":  []int{1, 2},
+		"zero":  0,
+		"when":  time.Date(2014, 3, 14, 15, 9, 26, 0, time.UTC),
+		"empty": "",
+	}
+	reverse := func(v interface{}, args ...string) (interface{}, error) {
+		r := []rune(sprint(v))
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r), nil
+	}
+	for _, test := range []struct {
+		template string
+		expected string
+	}{
+		{"{{name | upper}} {{name|lower}}", "GOPHER &lt;3 gopher &lt;3"},
+		{"{{{name | upper}}} {{&name | lower}}", "GOPHER <3 gopher <3"},
+		{`{{long | truncate 3}} {{long | truncate 3 "..."}} {{long | truncate 20 "..."}}`, "abc abc... abcdefghij"},
+		{`{{when | date "2006-01-02"}} {{when | date Jan}}`, "2014-03-14 Mar"},
+		{`{{missing | default "none"}} {{empty | default "-"}} {{zero | default 1}} {{name | default x}}`, "none - 1 Gopher &lt;3"},
+		{`{{tags | join}} {{nums | join "+"}}`, "a, b, c 1+2"},
+		{`{{name | reverse | upper}} {{#tags}}{{. | reverse}}{{/tags}}`, "3&lt; REHPOG abc"},
+		{`{{name | upper | reverse}}`, "3&lt; REHPOG"},
+	} {
+		template := New(Filters(map[string]Filter{"reverse": reverse}), SilentMiss(false))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatalf("%s: %s", test.template, err)
+		}
+		output, err := template.RenderString(context)
+		if err != nil {
+			t.Errorf("%s: %s", test.template, err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected %q got %q", test.template, test.expected, output)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, test := range []struct {
+		template string
+		options  []Option
+		parse    string
+		render   string
+	}{
+		{"{{name | upper}}", nil, "unrecognized character in action: U+007C '|'", ""},
+		{"{{name | reverse}}", []Option{Filters(nil)}, "1:1 syntax error: unknown filter reverse", ""},
+		{"{{name | }}", []Option{Filters(nil)}, "expected a filter name", ""},
+		{`{{name | default "x}}`, []Option{Filters(nil)}, "unterminated quoted string", ""},
+		{"{{name | truncate x}}", []Option{Filters(nil)}, "", "filter truncate: truncate expects a length, found \"x\""},
+		{"{{name | date}}", []Option{Filters(nil)}, "", "filter date: date expects a layout"},
+	} {
+		template := New(append(test.options, SilentMiss(false))...)
+		err := template.ParseString(test.template)
+		if test.parse != "" {
+			if err == nil || !strings.Contains(err.Error(), test.parse) {
+				t.Errorf("%s: expected parse error %q, got %v", test.template, test.parse, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = template.RenderString(map[string]string{"name": "x"})
+		if err == nil || err.Error() != test.render {
+			t.Errorf("%s: expected render error %q, got %v", test.template, test.render, err)
+		}
+	}
+}
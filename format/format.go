@@ -0,0 +1,118 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Package format implements the canonical formatting of mustache templates.
+//
+// The canonical form removes whitespace inside tags, so that {{ name }} is
+// formatted as {{name}} and {{ # section }} as {{#section}}. Text, including
+// the indentation of standalone tags, is preserved exactly as whitespace on
+// standalone lines may be part of the rendered output.
+package format
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/alexkappa/mustache"
+	"github.com/alexkappa/mustache/ast"
+)
+
+// Source formats the template src in canonical form. The template is parsed
+// using the default delimiters.
+func Source(src []byte) ([]byte, error) {
+	t := mustache.New()
+	if err := t.ParseBytes(src); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := Fprint(&b, t.Tree()); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Fprint writes the canonical form of tree to w. The tree is assumed to have
+// been parsed using the default delimiters.
+func Fprint(w io.Writer, tree *ast.Tree) error {
+	p := &printer{left: "{{", right: "}}"}
+	p.nodes(tree.Nodes)
+	_, err := w.Write(p.b.Bytes())
+	return err
+}
+
+// The printer type holds the state of Fprint.
+type printer struct {
+	b     bytes.Buffer
+	left  string // current left delimiter
+	right string // current right delimiter
+}
+
+func (p *printer) nodes(nodes []ast.Node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Text:
+			p.b.WriteString(n.Text)
+		case *ast.Var:
+			name := n.Name + filters(n.Filters)
+			switch {
+			case n.Triple:
+				p.tag("{" + name + "}")
+			case !n.Escaped:
+				p.tag("&" + name)
+			default:
+				p.tag(name)
+			}
+		case *ast.Section:
+			p.section(n)
+		case *ast.Partial:
+			p.tag(">" + n.Name)
+		case *ast.Comment:
+			p.tag("!" + n.Text)
+		case *ast.Delim:
+			p.tag("=" + n.Left + " " + n.Right + "=")
+			p.left, p.right = n.Left, n.Right
+		}
+	}
+}
+
+func (p *printer) tag(s string) {
+	p.b.WriteString(p.left)
+	p.b.WriteString(s)
+	p.b.WriteString(p.right)
+}
+
+// section prints the section n.
+func (p *printer) section(n *ast.Section) {
+	if n.Inverted {
+		p.tag("^" + n.Name)
+	} else {
+		p.tag("#" + n.Name)
+	}
+	p.nodes(n.Nodes)
+	p.tag("/" + n.Name)
+}
+
+// filters returns the canonical form of the filters of a variable, such as
+// ` | truncate 20 "..." | upper`. Arguments are quoted unless they look like
+// a name, made of letters, digits, underscores, dots and dashes.
+func filters(filters []ast.Filter) string {
+	var b strings.Builder
+	for _, f := range filters {
+		b.WriteString(" | ")
+		b.WriteString(f.Name)
+		for _, arg := range f.Args {
+			b.WriteByte(' ')
+			if arg == "" || strings.IndexFunc(arg, special) >= 0 || strings.IndexAny(arg[:1], ".-") == 0 {
+				arg = strconv.Quote(arg)
+			}
+			b.WriteString(arg)
+		}
+	}
+	return b.String()
+}
+
+func special(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_.-", r)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexkappa/mustache"
+)
+
+func TestSource(t *testing.T) {
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{"Hello {{ name }}!", "Hello {{name}}!"},
+		{"{{{ raw }}} {{& amp }} {{! keep  this }} {{> partial }}", "{{{raw}}} {{&amp}} {{! keep  this }} {{>partial}}"},
+		{"{{# a }}x{{/ a }}{{^ b }}y{{/ b }}", "{{#a}}x{{/a}}{{^b}}y{{/b}}"},
+		{"{{=<% %>=}}<% name %> <%={{ }}=%>{{ name }}", "{{=<% %>=}}<%name%> <%={{ }}=%>{{name}}"},
+		{"{{=  |  |  =}}| name |", "{{=| |=}}|name|"},
+		{
+			"<ul>\n  {{ #items }}\n  <li>{{.}}</li>\n      {{/items }}\n</ul>\n",
+			"<ul>\n  {{#items}}\n  <li>{{.}}</li>\n      {{/items}}\n</ul>\n",
+		},
+	} {
+		b, err := Source([]byte(test.input))
+		if err != nil {
+			t.Fatalf("%q: %s", test.input, err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("%q: unexpected output %q, expected %q", test.input, b, test.expected)
+		}
+		again, err := Source(b)
+		if err != nil {
+			t.Fatalf("%q: %s", b, err)
+		}
+		if string(again) != string(b) {
+			t.Errorf("%q: formatting is not idempotent %q", b, again)
+		}
+	}
+}
+
+func TestSourceRender(t *testing.T) {
+	input := "<ul>\n  {{ #items }}\n  <li>{{ name }}</li>\n      {{/items}}\n</ul>\n{{^ items }}\n  none\n    {{/ items}}\n"
+	b, err := Source([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"items": []map[string]string{{"name": "a"}, {"name": "b"}},
+	}
+	render := func(s string) string {
+		t.Helper()
+		template := mustache.New()
+		if err := template.ParseString(s); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return output
+	}
+	if expected, output := render(input), render(string(b)); output != expected {
+		t.Errorf("formatted template renders %q, expected %q", output, expected)
+	}
+}
+
+func TestFprintFilters(t *testing.T) {
+	template := mustache.New(mustache.Filters(nil))
+	if err := template.ParseString(`{{ name|truncate  20 "..." | upper }}{{{ items | join "-x"}}}{{&a|default ""}}`); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := Fprint(&b, template.Tree()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{{name | truncate 20 "..." | upper}}{{{items | join "-x"}}}{{&a | default ""}}`
+	if b.String() != expected {
+		t.Errorf("unexpected output %q, expected %q", b.String(), expected)
+	}
+}
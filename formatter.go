@@ -0,0 +1,120 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// The FormatFunc type formats the value of a variable as the text it renders
+// to. An error stops the rendering unless misses are silent.
+type FormatFunc func(v interface{}) (string, error)
+
+// Formatter sets the function formatting the values of variables which have no
+// formatter registered for their type with FormatType. By default, integers are
+// formatted in decimal, floats as if formatted by the %g verb of fmt, so large
+// and tiny ones have an exponent such as 1e+06 unless FloatFormat is used,
+// values implementing fmt.Stringer using their String method, and other values
+// as if formatted by fmt.Sprint.
+func Formatter(f FormatFunc) Option {
+	return func(t *Template) {
+		t.formatter = f
+	}
+}
+
+// FormatType registers f as the formatter of values of type typ.
+func FormatType(typ reflect.Type, f FormatFunc) Option {
+	return func(t *Template) {
+		if t.formatters == nil {
+			t.formatters = make(map[reflect.Type]FormatFunc)
+		}
+		t.formatters[typ] = f
+	}
+}
+
+// TimeLayout formats time.Time values using layout, as defined by the time
+// package.
+func TimeLayout(layout string) Option {
+	return FormatType(reflect.TypeOf(time.Time{}), func(v interface{}) (string, error) {
+		return v.(time.Time).Format(layout), nil
+	})
+}
+
+// FloatFormat formats float32 and float64 values using the format and the
+// precision of strconv.FormatFloat, such as 'f' and 2 for 0.30 rather than
+// 0.30000000000000004, or 'f' and -1 for 1000000 rather than 1e+06.
+func FloatFormat(format byte, prec int) Option {
+	return func(t *Template) {
+		FormatType(reflect.TypeOf(float32(0)), func(v interface{}) (string, error) {
+			return strconv.FormatFloat(float64(v.(float32)), format, prec, 32), nil
+		})(t)
+		FormatType(reflect.TypeOf(float64(0)), func(v interface{}) (string, error) {
+			return strconv.FormatFloat(v.(float64), format, prec, 64), nil
+		})(t)
+	}
+}
+
+// BytesAsString formats []byte values as strings rather than lists of numbers.
+func BytesAsString() Option {
+	return FormatType(reflect.TypeOf([]byte(nil)), func(v interface{}) (string, error) {
+		return string(v.([]byte)), nil
+	})
+}
+
+// NilAsEmpty formats nil pointers, maps, slices, functions, channels and
+// interfaces as empty text rather than <nil>, before any other formatter.
+func NilAsEmpty() Option {
+	return func(t *Template) {
+		t.nilEmpty = true
+	}
+}
+
+// format formats v using the formatters of the template.
+func (t *Template) format(v interface{}) (string, error) {
+	if t.nilEmpty && isNil(reflect.ValueOf(v)) {
+		return "", nil
+	}
+	if f, ok := t.formatters[reflect.TypeOf(v)]; ok {
+		return f(v)
+	}
+	if t.formatter != nil {
+		return t.formatter(v)
+	}
+	if t.methods != nil {
+		if err := t.formatMethod(v); err != nil {
+			return "", err
+		}
+	}
+	return sprint(v), nil
+}
+
+// formatMethod returns a *SandboxError if formatting v by default calls its
+// String or Error method, and the sandbox of t doesn't allow it.
+func (t *Template) formatMethod(v interface{}) error {
+	var name string
+	switch v.(type) {
+	case fmt.Stringer:
+		name = "String"
+	case error:
+		name = "Error"
+	default:
+		return nil
+	}
+	if typ := reflect.TypeOf(v); !t.methods(typ, name) {
+		return &SandboxError{Type: typ, Method: name}
+	}
+	return nil
+}
+
+func isNil(r reflect.Value) bool {
+	switch r.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return r.IsNil()
+	}
+	return false
+}
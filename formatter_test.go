@@ -0,0 +1,69 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatter(t *testing.T) {
+	var nilMap map[string]string
+	a, b := 0.1, 0.2
+	context := map[string]interface{}{
+		"float": a + b,
+		"small": float32(1.5),
+		"when":  time.Date(2014, 3, 14, 15, 9, 26, 0, time.UTC),
+		"bytes": []byte("<b>"),
+		"ptr":   (*int)(nil),
+		"map":   nilMap,
+		"int":   42,
+		"large": 1e6,
+	}
+	bracket := func(v interface{}) (string, error) {
+		return fmt.Sprintf("[%v]", v), nil
+	}
+	for _, test := range []struct {
+		template string
+		options  []Option
+		expected string
+	}{
+		{"{{float}} {{int}} {{ptr}}", nil, "0.30000000000000004 42 &lt;nil&gt;"},
+		{"{{large}} {{small}}", nil, "1e+06 1.5"},
+		{"{{large}} {{small}}", []Option{FloatFormat('f', -1)}, "1000000 1.5"},
+		{"{{float}} {{small}} {{int}}", []Option{FloatFormat('f', 2)}, "0.30 1.50 42"},
+		{"{{when}}", []Option{TimeLayout("2006-01-02 15:04")}, "2014-03-14 15:09"},
+		{"{{bytes}} {{{bytes}}}", []Option{BytesAsString()}, "&lt;b&gt; <b>"},
+		{"{{ptr}}|{{map}}|{{int}}", []Option{NilAsEmpty()}, "||42"},
+		{"{{int}} {{float}}", []Option{Formatter(bracket), FloatFormat('g', 1)}, "[42] 0.3"},
+		{"{{int}}", []Option{FormatType(reflect.TypeOf(0), bracket)}, "[42]"},
+	} {
+		template := New(test.options...)
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(context)
+		if err != nil {
+			t.Error(err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected %q got %q", test.template, test.expected, output)
+		}
+	}
+}
+
+func TestFormatterError(t *testing.T) {
+	fail := func(v interface{}) (string, error) {
+		return "", errors.New("can't format")
+	}
+	template := New(Formatter(fail), SilentMiss(false))
+	if err := template.ParseString("{{int}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := template.RenderString(map[string]int{"int": 1}); err == nil || err.Error() != "can't format" {
+		t.Errorf("expected a formatting error, got %v", err)
+	}
+}
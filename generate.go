@@ -0,0 +1,496 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Generate writes the Go source of a function named fn which renders t to an
+// io.Writer using a *T as context, where T is the named struct type typ. The
+// generated function has the signature
+//
+//	func fn(w io.Writer, data *T) error
+//
+// and is placed in the same package as typ. Every name used in the template is
+// resolved against typ at generation time following the same rules as lookup,
+// so the generated code doesn't use reflection when rendering. Names which can
+// never be resolved are reported as errors. Partials registered with t are
+// inlined in the generated function.
+//
+// Options changing how values are rendered, such as SilentMiss(false),
+// Formatter, Locale, Filters or Translate, are not supported by the generated
+// code, and templates using them are reported as errors rather than rendering
+// differently.
+func (t *Template) Generate(w io.Writer, fn string, typ *types.Named) error {
+	if typ.Obj().Pkg() == nil {
+		return fmt.Errorf("type %s has no package", typ)
+	}
+	if err := generateOptions(t); err != nil {
+		return err
+	}
+	g := &generator{
+		template: t,
+		pkg:      typ.Obj().Pkg(),
+		imports:  map[string]bool{"io": true, "strings": true},
+	}
+	scope := genScope{expr: "(*data)", typ: typ}
+	if err := g.nodes(t, t.elems, []genScope{scope}); err != nil {
+		return err
+	}
+	return g.writeFile(w, fn, typ)
+}
+
+// The genScope type represents an element of the context chain known at
+// generation time. Its expr is a Go expression evaluating to a value of typ.
+type genScope struct {
+	expr string
+	typ  types.Type
+}
+
+// The generator type holds the state of a Go source generation.
+type generator struct {
+	template *Template
+	pkg      *types.Package
+	body     bytes.Buffer
+	imports  map[string]bool
+	vars     int
+	depth    int
+	write    bool // whether the write helper is used.
+	text     bool // whether the text helper is used.
+	escape   bool // whether the escape helper is used.
+}
+
+// maxGenerateDepth is the maximum level of partials that will be inlined
+// before giving up. Templates with recursive partials can not be generated.
+const maxGenerateDepth = 32
+
+func (g *generator) printf(format string, v ...interface{}) {
+	fmt.Fprintf(&g.body, format, v...)
+	g.body.WriteByte('\n')
+}
+
+// tmp returns a new unique variable name.
+func (g *generator) tmp() string {
+	g.vars++
+	return "v" + strconv.Itoa(g.vars)
+}
+
+// errorf returns an error prefixed with the name of template t.
+func (g *generator) errorf(t *Template, format string, v ...interface{}) error {
+	if t.name != "" {
+		format = t.name + ": " + format
+	}
+	return fmt.Errorf(format, v...)
+}
+
+// nodes generates the code that renders elems of template t using scopes as
+// the context chain.
+func (g *generator) nodes(t *Template, elems []node, scopes []genScope) error {
+	for _, elem := range elems {
+		var err error
+		switch n := elem.(type) {
+		case textNode:
+			g.text = true
+			g.printf("text(%q)", string(n))
+		case commentNode, delimNode, *delimNode:
+			g.printf("hasTag = true")
+		case *varNode:
+			err = g.varNode(t, n, scopes)
+		case *sectionNode:
+			err = g.sectionNode(t, n, scopes)
+		case *partialNode:
+			err = g.partialNode(t, n, scopes)
+		default:
+			err = g.errorf(t, "unsupported node %s", elem)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *generator) varNode(t *Template, n *varNode, scopes []genScope) error {
+	if len(n.filters) > 0 {
+		return g.errorf(t, "filters of %s are not supported", n.name)
+	}
+	g.printf("hasText = true")
+	return g.resolve(t, n.name, scopes, func(v genScope) error {
+		s, err := g.stringer(t, n.name, v)
+		if err != nil {
+			return err
+		}
+		if n.escape && t.escape == EscapeHTML {
+			g.escape = true
+			s = "escape(" + s + ")"
+		}
+		g.write = true
+		if isNillable(v.typ) {
+			g.printf("if %s != nil {", v.expr)
+			defer g.printf("}")
+		}
+		g.printf("write(%s)", s)
+		return nil
+	}, func() error { return nil })
+}
+
+func (g *generator) sectionNode(t *Template, n *sectionNode, scopes []genScope) error {
+	g.printf("hasTag = true")
+	err := g.resolve(t, n.name, scopes, func(v genScope) error {
+		cond, err := g.truth(t, n.name, v)
+		if err != nil {
+			return err
+		}
+		if n.inverted {
+			g.printf("if !(%s) {", cond)
+			defer g.printf("}")
+			return g.nodes(t, n.elems, append([]genScope{v}, scopes...))
+		}
+		switch v.typ.Underlying().(type) {
+		case *types.Slice, *types.Array:
+			elem := genScope{expr: g.tmp(), typ: elemType(v.typ)}
+			g.printf("for _, %s := range %s {", elem.expr, v.expr)
+			defer g.printf("}")
+			return g.nodes(t, n.elems, append([]genScope{elem}, scopes...))
+		}
+		g.printf("if %s {", cond)
+		defer g.printf("}")
+		return g.nodes(t, n.elems, append([]genScope{v}, scopes...))
+	}, func() error {
+		if n.inverted {
+			return g.nodes(t, n.elems, scopes)
+		}
+		return nil
+	})
+	g.printf("hasTag = true")
+	return err
+}
+
+func (g *generator) partialNode(t *Template, n *partialNode, scopes []genScope) error {
+	g.printf("hasTag = true")
+	p, ok := t.partials[n.name]
+	if !ok {
+		return g.errorf(t, "partial %q not found", n.name)
+	}
+	if g.depth >= maxGenerateDepth {
+		return g.errorf(t, "partial %q is nested too deeply", n.name)
+	}
+	// Partials are generated with the options of the template including them,
+	// the same way partialNode.render does.
+	partial := *t
+	partial.name, partial.elems, partial.tree = p.name, p.elems, p.tree
+	g.depth++
+	defer func() { g.depth-- }()
+	if err := g.nodes(&partial, partial.elems, scopes); err != nil {
+		return err
+	}
+	g.printf("flush()")
+	return nil
+}
+
+// generateOptions returns an error if t uses options which the generated code
+// doesn't support.
+func generateOptions(t *Template) error {
+	var option string
+	switch {
+	case !t.silentMiss:
+		option = "SilentMiss(false)"
+	case t.formatter != nil || len(t.formatters) > 0:
+		option = "Formatter, FormatType or Locale"
+	case t.nilEmpty:
+		option = "NilAsEmpty"
+	case t.catalog != nil:
+		option = "Translate"
+	case t.methods != nil:
+		option = "Sandbox"
+	case t.limits != limits{}:
+		option = "limits"
+	default:
+		return nil
+	}
+	if t.name != "" {
+		return fmt.Errorf("%s: option %s is not supported", t.name, option)
+	}
+	return fmt.Errorf("option %s is not supported", option)
+}
+
+// resolve generates the code which looks up name in the context chain scopes.
+// The code generated by found is used when the name is resolved to a value,
+// while the code generated by miss is used when it is not. If the name can
+// never be resolved an error is returned.
+func (g *generator) resolve(t *Template, name string, scopes []genScope, found func(genScope) error, miss func() error) error {
+	if name == "." {
+		if len(scopes) == 0 {
+			return miss()
+		}
+		return found(scopes[0])
+	}
+	if strings.Contains(name, ".") {
+		parts := strings.SplitN(name, ".", 2)
+		return g.resolve(t, parts[0], scopes, func(v genScope) error {
+			return g.resolve(t, parts[1], []genScope{v}, found, miss)
+		}, miss)
+	}
+	return g.resolveIn(t, name, scopes, false, found, miss)
+}
+
+// resolveIn looks up name in each element of scopes in turn. If dynamic is
+// true, a previous scope may have matched name depending on the data so a
+// missing name is not an error.
+func (g *generator) resolveIn(t *Template, name string, scopes []genScope, dynamic bool, found func(genScope) error, miss func() error) error {
+	if len(scopes) == 0 {
+		if dynamic {
+			return miss()
+		}
+		return g.errorf(t, "failed to lookup %s", name)
+	}
+	s := scopes[0]
+	next := func() error {
+		return g.resolveIn(t, name, scopes[1:], dynamic, found, miss)
+	}
+	switch u := s.typ.Underlying().(type) {
+	case *types.Struct:
+		obj, _, _ := types.LookupFieldOrMethod(s.typ, false, g.pkg, name)
+		switch obj := obj.(type) {
+		case *types.Var:
+			if obj.Exported() {
+				return found(genScope{s.expr + "." + name, obj.Type()})
+			}
+		case *types.Func:
+			sig := obj.Type().(*types.Signature)
+			if obj.Exported() && sig.Params().Len() == 0 && sig.Results().Len() == 1 {
+				v := g.tmp()
+				g.printf("%s := %s.%s()", v, s.expr, name)
+				return found(genScope{v, sig.Results().At(0).Type()})
+			}
+		}
+		for i := 0; i < u.NumFields(); i++ {
+			if reflect.StructTag(u.Tag(i)).Get("template") == name {
+				return found(genScope{s.expr + "." + u.Field(i).Name(), u.Field(i).Type()})
+			}
+		}
+	case *types.Map:
+		key, ok := u.Key().Underlying().(*types.Basic)
+		if !ok || key.Kind() != types.String {
+			break
+		}
+		k := strconv.Quote(name)
+		if named, ok := u.Key().(*types.Named); ok {
+			if named.Obj().Pkg() != g.pkg {
+				return g.errorf(t, "unsupported map key type %s", named)
+			}
+			k = named.Obj().Name() + "(" + k + ")"
+		}
+		v := g.tmp()
+		g.printf("if %s, ok := %s[%s]; ok {", v, s.expr, k)
+		if err := found(genScope{v, u.Elem()}); err != nil {
+			return err
+		}
+		g.printf("} else {")
+		n := g.body.Len()
+		if err := g.resolveIn(t, name, scopes[1:], true, found, miss); err != nil {
+			return err
+		}
+		if g.body.Len() == n {
+			// Nothing to do if the key is missing, so drop the else branch.
+			g.body.Truncate(n - len("} else {\n"))
+		}
+		g.printf("}")
+		return nil
+	case *types.Interface:
+		return g.errorf(t, "can not resolve %s on %s", name, s.typ)
+	}
+	return next()
+}
+
+// stringer returns an expression which formats v as a string, following the
+// rules of the print function.
+func (g *generator) stringer(t *Template, name string, v genScope) (string, error) {
+	if m, ok := method(v.typ, "String"); ok {
+		if isStringer(m) {
+			return v.expr + ".String()", nil
+		}
+	}
+	if b, ok := v.typ.Underlying().(*types.Basic); ok {
+		switch {
+		case b.Info()&types.IsString != 0:
+			if v.typ == types.Typ[types.String] {
+				return v.expr, nil
+			}
+			return "string(" + v.expr + ")", nil
+		case b.Info()&types.IsUnsigned != 0:
+			g.imports["strconv"] = true
+			return "strconv.FormatUint(uint64(" + v.expr + "), 10)", nil
+		case b.Info()&types.IsInteger != 0:
+			g.imports["strconv"] = true
+			return "strconv.FormatInt(int64(" + v.expr + "), 10)", nil
+		case b.Info()&types.IsFloat != 0:
+			g.imports["strconv"] = true
+			size := 64
+			if b.Kind() == types.Float32 {
+				size = 32
+			}
+			return fmt.Sprintf("strconv.FormatFloat(float64(%s), 'g', -1, %d)", v.expr, size), nil
+		case b.Info()&types.IsBoolean != 0:
+			g.imports["strconv"] = true
+			return "strconv.FormatBool(bool(" + v.expr + "))", nil
+		}
+	}
+	g.imports["fmt"] = true
+	return "fmt.Sprint(" + v.expr + ")", nil
+}
+
+// truth returns a boolean expression which evaluates the truth of v, following
+// the rules of the truth function.
+func (g *generator) truth(t *Template, name string, v genScope) (string, error) {
+	switch u := v.typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return v.expr, nil
+		case u.Info()&types.IsString != 0:
+			return v.expr + ` != ""`, nil
+		case u.Info()&types.IsNumeric != 0:
+			return v.expr + " > 0", nil
+		}
+	case *types.Slice, *types.Array:
+		return "len(" + v.expr + ") > 0", nil
+	case *types.Pointer:
+		cond, err := g.truth(t, name, genScope{"(*" + v.expr + ")", u.Elem()})
+		if err != nil {
+			return "", err
+		}
+		return v.expr + " != nil && " + cond, nil
+	case *types.Map, *types.Chan, *types.Signature:
+		return v.expr + " != nil", nil
+	case *types.Struct:
+		return "true", nil
+	}
+	return "", g.errorf(t, "can not determine truth of %s of type %s", name, v.typ)
+}
+
+// writeFile writes the generated function fn with its helpers and package
+// clause to w.
+func (g *generator) writeFile(w io.Writer, fn string, typ *types.Named) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by mustache-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", g.pkg.Name())
+	imports := make([]string, 0, len(g.imports))
+	for i := range g.imports {
+		imports = append(imports, strconv.Quote(i))
+	}
+	sort.Strings(imports)
+	fmt.Fprintf(&b, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	fmt.Fprintf(&b, "// %s renders the %s template to w using data as context.\n", fn, g.templateName())
+	fmt.Fprintf(&b, "func %s(w io.Writer, data *%s) error {\n", fn, typ.Obj().Name())
+	fmt.Fprintf(&b, "var (\nline strings.Builder\nhasText, hasTag bool\nerr error\n)\n")
+	fmt.Fprintf(&b, "%s\n", genFlush)
+	if g.write {
+		fmt.Fprintf(&b, "%s\n", genWrite)
+	}
+	if g.text {
+		fmt.Fprintf(&b, "%s\n", genText)
+	}
+	if g.escape {
+		fmt.Fprintf(&b, "%s\n", genEscape)
+	}
+	b.Write(g.body.Bytes())
+	fmt.Fprintf(&b, "flush()\nreturn err\n}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated source: %s", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func (g *generator) templateName() string {
+	if g.template.name != "" {
+		return strconv.Quote(g.template.name)
+	}
+	return "mustache"
+}
+
+// The helpers of the generated code mirror the behavior of the writer type, so
+// that standalone lines are removed from the output in the same way.
+const (
+	genFlush = `flush := func() {
+	if err == nil && (hasText || !hasTag) {
+		_, err = io.WriteString(w, line.String())
+	}
+	line.Reset()
+	hasText, hasTag = false, false
+}`
+	genWrite = `write := func(s string) {
+	for _, r := range s {
+		line.WriteRune(r)
+		if r == '\n' {
+			flush()
+		}
+	}
+}`
+	genText = `text := func(s string) {
+	for _, r := range s {
+		if r != ' ' && r != '\t' && r != '\n' && r != '\r' {
+			hasText = true
+		}
+		line.WriteRune(r)
+		if r == '\n' {
+			flush()
+		}
+	}
+}`
+	genEscape = `escape := strings.NewReplacer(
+	"\"", "&quot;",
+	"'", "&apos;",
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+).Replace`
+)
+
+// method looks up the method name in the method set of typ.
+func method(typ types.Type, name string) (*types.Func, bool) {
+	obj, _, _ := types.LookupFieldOrMethod(typ, false, nil, name)
+	m, ok := obj.(*types.Func)
+	return m, ok
+}
+
+// isStringer reports whether m has the signature of fmt.Stringer's String.
+func isStringer(m *types.Func) bool {
+	sig := m.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	return types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+}
+
+// isNillable reports whether values of typ may be nil. Nil values are not
+// printed, the same way a nil value is missed by lookup.
+func isNillable(typ types.Type) bool {
+	switch typ.Underlying().(type) {
+	case *types.Interface:
+		return true
+	}
+	return false
+}
+
+// elemType returns the element type of a slice or array type.
+func elemType(typ types.Type) types.Type {
+	switch u := typ.Underlying().(type) {
+	case *types.Slice:
+		return u.Elem()
+	case *types.Array:
+		return u.Elem()
+	}
+	return nil
+}
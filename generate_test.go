@@ -0,0 +1,365 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	goparser "go/parser"
+	gotoken "go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// generateSource declares the context type of the generated templates. The
+// same source is used to build the data rendered by the generated code and by
+// the interpreter in generateMain.
+const generateSource = `package page
+
+type Color int
+
+func (c Color) String() string { return [...]string{"red", "green"}[c] }
+
+type Item struct {
+	Name  string
+	Price float64
+	Tags  []string
+}
+
+type Page struct {
+	Title   string
+	Count   int
+	Visible bool
+	Hidden  bool
+	Items   []Item
+	Meta    map[string]string
+	Color   Color
+	Label   string ` + "`template:\"label\"`" + `
+}
+
+var Data = Page{
+	Title:   "Tom & <Jerry>",
+	Count:   3,
+	Visible: true,
+	Items: []Item{
+		{"apple", 0.5, []string{"red", "fruit"}},
+		{"bread", 2, nil},
+	},
+	Meta:  map[string]string{"author": "Alex"},
+	Color: 1,
+	Label: "labeled",
+}
+`
+
+var generateTests = []struct {
+	template string
+	partials map[string]string
+}{
+	{template: "Hello {{Title}}! {{{Title}}} {{&Title}}"},
+	{template: "{{Count}} {{Visible}} {{Color}} {{label}} {{Meta.author}}{{Meta.missing}}"},
+	{template: "{{#Items}}\n  {{Name}}: {{Price}}{{#Tags}} [{{.}}]{{/Tags}}{{^Tags}} none{{/Tags}}\n{{/Items}}\n"},
+	{template: "{{#Visible}}\n{{Title}}\n{{/Visible}}\n{{^Hidden}}\nshown\n{{/Hidden}}\n"},
+	{template: "{{#Meta}}{{author}} {{Count}}{{/Meta}} {{! comment }}\n  {{=| |=}}\n|Title|"},
+	{
+		template: "{{#Items}}\n{{>item}}\n{{/Items}}",
+		partials: map[string]string{"item": "* {{Name}} of {{Title}}\n"},
+	},
+}
+
+// The generateCase type is a template generated by TestGenerate, along with
+// the names of its context type and of the variable holding its data in the
+// generated page package.
+type generateCase struct {
+	template string
+	partials map[string]string
+	typ      string
+	data     string
+}
+
+func TestGenerate(t *testing.T) {
+	var cases []generateCase
+	for _, test := range generateTests {
+		cases = append(cases, generateCase{test.template, test.partials, "Page", "Data"})
+	}
+	// The spec tests whose data can be given a Go type are generated as well,
+	// so that the generated code is checked against the interpreter on them.
+	var spec strings.Builder
+	spec.WriteString("package page\n")
+	specCases := specGenerateCases(&spec)
+
+	fset := gotoken.NewFileSet()
+	var sources []*ast.File
+	for name, src := range map[string]string{"page.go": generateSource, "spec.go": spec.String()} {
+		f, err := goparser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, f)
+	}
+	pkg, err := new(types.Config).Check("page", fset, sources, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "mustache-generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"page/page.go": generateSource, "page/spec.go": spec.String()}
+	generate := func(i int, c generateCase) error {
+		template := New()
+		if err := template.ParseString(c.template); err != nil {
+			return err
+		}
+		for name, s := range c.partials {
+			p := New(Name(name))
+			if err := p.ParseString(s); err != nil {
+				return err
+			}
+			template.Option(Partial(p))
+		}
+		typ := pkg.Scope().Lookup(c.typ).Type().(*types.Named)
+		var b bytes.Buffer
+		if err := template.Generate(&b, fmt.Sprintf("Render%d", i), typ); err != nil {
+			return err
+		}
+		files[fmt.Sprintf("page/render%d.go", i)] = b.String()
+		return nil
+	}
+	for i, c := range cases {
+		if err := generate(i, c); err != nil {
+			t.Fatalf("%q: %s", c.template, err)
+		}
+	}
+	for _, c := range specCases {
+		// Spec templates using names which can't be resolved, or values whose
+		// type is only known when rendering, can't be generated.
+		if err := generate(len(cases), c); err == nil {
+			cases = append(cases, c)
+		}
+	}
+
+	// Make sure the generated code type checks along with its context type.
+	var parsed []*ast.File
+	for name, src := range files {
+		f, err := goparser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, f)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("page", fset, parsed, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if testing.Short() {
+		t.Skip("skip running generated code in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("skip running generated code as the go command is not available")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["go.mod"] = "module gentest\n\nrequire github.com/alexkappa/mustache v0.0.0\n\nreplace github.com/alexkappa/mustache => " + wd + "\n"
+	files["main.go"] = generateMain(cases)
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(gobin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+}
+
+// specGenerateCases returns the spec tests whose data can be given a Go type,
+// writing the declarations of their types and data to src.
+func specGenerateCases(src *strings.Builder) []generateCase {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		if !strings.HasPrefix(name, "~") { // optional modules, such as lambdas
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var cases []generateCase
+	for _, name := range names {
+		for _, test := range specs[name].Tests {
+			if _, ok := test.Data.(map[string]interface{}); !ok {
+				continue
+			}
+			g := &specTypes{prefix: fmt.Sprintf("Spec%d", len(cases)), structs: make(map[string]string)}
+			typ, lit, ok := g.value(test.Data)
+			if !ok {
+				continue
+			}
+			data := g.prefix + "Data"
+			src.WriteString(g.decls.String())
+			fmt.Fprintf(src, "var %s = %s\n", data, lit)
+			cases = append(cases, generateCase{test.Template, test.Partials, typ, data})
+		}
+	}
+	return cases
+}
+
+// The specTypes type declares the Go types of the data of a spec test. Objects
+// are declared as structs whose fields are named after the keys using the
+// template tag, and arrays as slices of the type shared by their elements.
+type specTypes struct {
+	prefix  string
+	decls   strings.Builder
+	structs map[string]string // names of the declared structs, by their fields
+}
+
+// value returns the type and the literal of v, if v can be given a type.
+func (g *specTypes) value(v interface{}) (typ, lit string, ok bool) {
+	switch v := v.(type) {
+	case string:
+		return "string", strconv.Quote(v), true
+	case bool:
+		return "bool", strconv.FormatBool(v), true
+	case float64:
+		return "float64", strconv.FormatFloat(v, 'g', -1, 64), true
+	case []interface{}:
+		elem, lits := "string", make([]string, len(v))
+		for i, e := range v {
+			typ, lit, ok := g.value(e)
+			if !ok || i > 0 && typ != elem {
+				return "", "", false
+			}
+			elem, lits[i] = typ, lit
+		}
+		return "[]" + elem, "[]" + elem + "{" + strings.Join(lits, ", ") + "}", true
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var fields, values []string
+		for i, k := range keys {
+			typ, lit, ok := g.value(v[k])
+			if !ok || strings.ContainsAny(k, "`\"\\") {
+				return "", "", false
+			}
+			fields = append(fields, fmt.Sprintf("F%d %s `template:%q`", i, typ, k))
+			values = append(values, fmt.Sprintf("F%d: %s", i, lit))
+		}
+		body := "struct {\n" + strings.Join(fields, "\n") + "\n}"
+		name, ok := g.structs[body]
+		if !ok {
+			name = fmt.Sprintf("%sT%d", g.prefix, len(g.structs))
+			g.structs[body] = name
+			fmt.Fprintf(&g.decls, "type %s %s\n", name, body)
+		}
+		return name, name + "{" + strings.Join(values, ", ") + "}", true
+	}
+	return "", "", false
+}
+
+// generateMain returns the source of a program rendering each case using both
+// the interpreter and the generated code and comparing the output.
+func generateMain(cases []generateCase) string {
+	var b strings.Builder
+	b.WriteString(`package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/alexkappa/mustache"
+	"gentest/page"
+)
+
+func main() {
+	failed := false
+	check := func(template string, partials map[string]string, data interface{}, render func(*bytes.Buffer) error) {
+		t := mustache.New()
+		if err := t.ParseString(template); err != nil {
+			panic(err)
+		}
+		for name, s := range partials {
+			p := mustache.New(mustache.Name(name))
+			if err := p.ParseString(s); err != nil {
+				panic(err)
+			}
+			t.Option(mustache.Partial(p))
+		}
+		expected, _ := t.RenderString(data)
+		var b bytes.Buffer
+		if err := render(&b); err != nil {
+			panic(err)
+		}
+		if b.String() != expected {
+			fmt.Printf("template %q: expected %q got %q\n", template, expected, b.String())
+			failed = true
+		}
+	}
+`)
+	for i, c := range cases {
+		fmt.Fprintf(&b, "\tcheck(%q, %#v, page.%s, func(b *bytes.Buffer) error { data := page.%s; return page.Render%d(b, &data) })\n", c.template, c.partials, c.data, c.data, i)
+	}
+	b.WriteString(`	if failed {
+		os.Exit(1)
+	}
+}
+`)
+	return b.String()
+}
+
+func TestGenerateOptions(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	f, err := goparser.ParseFile(fset, "page.go", generateSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("page", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := pkg.Scope().Lookup("Page").Type().(*types.Named)
+	for _, test := range []struct {
+		options []Option
+		err     string
+	}{
+		{[]Option{SilentMiss(false)}, "option SilentMiss(false) is not supported"},
+		{[]Option{Locale("de-DE")}, "option Formatter, FormatType or Locale is not supported"},
+		{[]Option{FloatFormat('f', 2)}, "option Formatter, FormatType or Locale is not supported"},
+		{[]Option{NilAsEmpty()}, "option NilAsEmpty is not supported"},
+		{[]Option{Translate(NewCatalog(), "en")}, "option Translate is not supported"},
+		{[]Option{Name("page"), MaxOutput(10)}, "page: option limits is not supported"},
+	} {
+		template := New(test.options...)
+		if err := template.ParseString("{{Title}}"); err != nil {
+			t.Fatal(err)
+		}
+		err := template.Generate(ioutil.Discard, "Render", typ)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("expected error %q, got %v", test.err, err)
+		}
+	}
+}
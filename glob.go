@@ -0,0 +1,125 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The Naming type describes how templates loaded by ParseGlob and ParseFS are
+// named after the path of their file.
+type Naming int
+
+const (
+	// NamePath names a template after its path without the extension, such as
+	// "pages/home" for pages/home.mustache.
+	NamePath Naming = iota
+	// NameBase names a template after its file name without the extension,
+	// such as "home" for pages/home.mustache.
+	NameBase
+	// NameFile names a template after its path, such as "pages/home.mustache".
+	NameFile
+)
+
+func (n Naming) name(p string) string {
+	switch n {
+	case NameBase:
+		p = path.Base(p)
+		return strings.TrimSuffix(p, path.Ext(p))
+	case NameFile:
+		return p
+	default:
+		return strings.TrimSuffix(p, path.Ext(p))
+	}
+}
+
+// ParseGlob parses the files matching pattern, as defined by filepath.Match,
+// and returns the templates by name. Templates are named by naming after their
+// path relative to the directory of pattern which contains no special
+// characters. Every template is registered as a partial of every other one, so
+// that they may include each other by name.
+//
+// The options are applied to every template.
+func ParseGlob(pattern string, naming Naming, options ...Option) (map[string]*Template, error) {
+	dir, rest := splitGlob(pattern)
+	return parseFS(os.DirFS(dir), naming, []string{rest}, options)
+}
+
+// ParseFS is like ParseGlob, but parses the files of fsys matching any of the
+// patterns, as defined by path.Match. Templates are named by naming after
+// their path in fsys.
+func ParseFS(fsys fs.FS, naming Naming, patterns ...string) (map[string]*Template, error) {
+	return parseFS(fsys, naming, patterns, nil)
+}
+
+func parseFS(fsys fs.FS, naming Naming, patterns []string, options []Option) (map[string]*Template, error) {
+	templates := make(map[string]*Template)
+	paths := make(map[string]string) // paths of the files, by template name
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern matches no files: %#q", pattern)
+		}
+		for _, p := range matches {
+			if seen[p] {
+				continue // matched by a previous pattern
+			}
+			b, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return nil, err
+			}
+			t := New(options...)
+			name := naming.name(p)
+			if other, ok := paths[name]; ok {
+				return nil, fmt.Errorf("template %q defined by both %s and %s", name, other, p)
+			}
+			t.Option(Name(name))
+			if err := t.ParseBytes(b); err != nil {
+				return nil, fmt.Errorf("%s:%s", p, err)
+			}
+			templates[name] = t
+			paths[name] = p
+			seen[p] = true
+		}
+	}
+	for _, t := range templates {
+		for _, p := range templates {
+			t.Option(Partial(p))
+		}
+	}
+	return templates, nil
+}
+
+// splitGlob splits pattern into its leading directory which contains no
+// special characters, and the rest of the pattern using forward slashes.
+func splitGlob(pattern string) (dir, rest string) {
+	dir = filepath.Dir(pattern)
+	for hasMeta(dir) {
+		dir = filepath.Dir(dir)
+	}
+	rest, err := filepath.Rel(dir, pattern)
+	if err != nil {
+		return ".", filepath.ToSlash(pattern)
+	}
+	return dir, filepath.ToSlash(rest)
+}
+
+// hasMeta reports whether p contains any of the special characters recognized
+// by filepath.Match.
+func hasMeta(p string) bool {
+	magic := `*?[\`
+	if runtime.GOOS == "windows" {
+		magic = `*?[`
+	}
+	return strings.ContainsAny(p, magic)
+}
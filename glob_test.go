@@ -0,0 +1,96 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.mustache":        {Data: []byte("<{{>pages/home}}>")},
+		"pages/home.mustache":    {Data: []byte("{{#layout}}{{>layout}}{{/layout}}{{>partials/item}}")},
+		"partials/item.mustache": {Data: []byte("{{name}}")},
+		"pages/home.txt":         {Data: []byte("ignored")},
+	}
+	templates, err := ParseFS(fsys, NamePath, "*.mustache", "*/*.mustache", "pages/*")
+	if err == nil {
+		t.Fatal("expected an error for a duplicate template name")
+	}
+	templates, err = ParseFS(fsys, NamePath, "*.mustache", "*/*.mustache", "pages/*.mustache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if s := strings.Join(names, " "); s != "layout pages/home partials/item" {
+		t.Errorf("unexpected templates %s", s)
+	}
+	s, err := templates["layout"].RenderString(map[string]interface{}{
+		"name":   "world",
+		"layout": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "<world>" {
+		t.Errorf("expected %q, got %q", "<world>", s)
+	}
+	if _, err := ParseFS(fsys, NamePath, "*.html"); err == nil {
+		t.Error("expected an error for a pattern matching no files")
+	}
+}
+
+func TestParseGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mustache-glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]string{
+		"home.mustache":  "{{|>about|}}",
+		"about.mustache": "{{|name|}}",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "pages", name), []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		naming Naming
+		name   string
+	}{
+		{NamePath, "pages/home"},
+		{NameBase, "home"},
+		{NameFile, "pages/home.mustache"},
+	} {
+		templates, err := ParseGlob(filepath.Join(dir, "*", "*.mustache"), test.naming, Delimiters("{{|", "|}}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		template, ok := templates[test.name]
+		if !ok {
+			t.Errorf("%d: missing template %s", test.naming, test.name)
+			continue
+		}
+		s, _ := template.RenderString(map[string]string{"name": "world"})
+		expected := ""
+		if test.naming == NameBase {
+			expected = "world"
+		}
+		if s != expected {
+			t.Errorf("%d: expected %q, got %q", test.naming, expected, s)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Package http renders mustache templates as the responses of HTTP handlers.
+//
+// Templates are rendered to a buffer before anything is written to the
+// response, so that rendering errors result in a 500 Internal Server Error
+// response instead of a partially written page.
+//
+//	page := mustache.New()
+//	err := page.ParseString("Hello, {{name}}!")
+//	// ...
+//	http.Handle("/hello", mhttp.Handler(page, func(r *http.Request) (interface{}, error) {
+//		return map[string]string{"name": r.URL.Query().Get("name")}, nil
+//	}, mhttp.ETag()))
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alexkappa/mustache"
+)
+
+// The DataFunc type returns the context used to render a template in response
+// to r.
+type DataFunc func(r *http.Request) (interface{}, error)
+
+// The Set interface is implemented by collections of templates which can be
+// looked up by name, such as a *mustache.Cache or Templates.
+type Set interface {
+	Lookup(name string) (*mustache.Template, error)
+}
+
+// The Templates type is a Set of templates, such as the ones returned by
+// mustache.ParseGlob and mustache.ParseFS.
+type Templates map[string]*mustache.Template
+
+// Lookup returns the template named name.
+func (t Templates) Lookup(name string) (*mustache.Template, error) {
+	if template, ok := t[name]; ok {
+		return template, nil
+	}
+	return nil, fmt.Errorf("template %s not found", name)
+}
+
+// The Option type describes functional options used with handlers.
+type Option func(*handler)
+
+// ETag enables the generation of ETag headers from the rendered responses.
+// Requests with a matching If-None-Match header are answered with 304 Not
+// Modified and no body.
+func ETag() Option {
+	return func(h *handler) {
+		h.etag = true
+	}
+}
+
+// ContentType sets the Content-Type header of the responses. By default it is
+// "text/html; charset=utf-8" for templates escaping HTML, and
+// "text/plain; charset=utf-8" for templates which don't escape values.
+func ContentType(s string) Option {
+	return func(h *handler) {
+		h.contentType = s
+	}
+}
+
+// ErrorLog sets the logger used to report errors. By default errors are
+// reported by the log package's standard logger.
+func ErrorLog(l *log.Logger) Option {
+	return func(h *handler) {
+		h.errorLog = l
+	}
+}
+
+// Handler returns a handler which responds to requests by rendering t, using
+// the context returned by data. If data is nil the template is rendered without
+// context.
+func Handler(t *mustache.Template, data DataFunc, options ...Option) http.Handler {
+	return newHandler(func() (*mustache.Template, error) { return t, nil }, data, options)
+}
+
+// SetHandler is like Handler, but renders the template named name of set. The
+// template is looked up on every request, so that a *mustache.Cache may reload
+// it.
+func SetHandler(set Set, name string, data DataFunc, options ...Option) http.Handler {
+	return newHandler(func() (*mustache.Template, error) { return set.Lookup(name) }, data, options)
+}
+
+type handler struct {
+	template    func() (*mustache.Template, error)
+	data        DataFunc
+	etag        bool
+	contentType string
+	errorLog    *log.Logger
+}
+
+func newHandler(template func() (*mustache.Template, error), data DataFunc, options []Option) *handler {
+	h := &handler{template: template, data: data}
+	for _, optionFn := range options {
+		optionFn(h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, err := h.template()
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+	var context []interface{}
+	if h.data != nil {
+		v, err := h.data(r)
+		if err != nil {
+			h.error(w, err)
+			return
+		}
+		context = append(context, v)
+	}
+	var b bytes.Buffer
+	if err := t.Render(&b, context...); err != nil {
+		h.error(w, err)
+		return
+	}
+	header := w.Header()
+	if h.etag {
+		sum := sha256.Sum256(b.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		header.Set("ETag", etag)
+		if match(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	contentType := h.contentType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+		if t.EscapeMode() == mustache.EscapeNone {
+			contentType = "text/plain; charset=utf-8"
+		}
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.Itoa(b.Len()))
+	w.WriteHeader(http.StatusOK)
+	b.WriteTo(w)
+}
+
+// error logs err and responds with 500 Internal Server Error. The error itself
+// is not written to the response, since it may reveal details of the server.
+func (h *handler) error(w http.ResponseWriter, err error) {
+	if h.errorLog != nil {
+		h.errorLog.Print(err)
+	} else {
+		log.Print(err)
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// match reports whether the If-None-Match header value matches etag.
+func match(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag || tag == "W/"+etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package http
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexkappa/mustache"
+)
+
+func TestHandler(t *testing.T) {
+	page := mustache.New(mustache.SilentMiss(false))
+	if err := page.ParseString("Hello, {{name}}!"); err != nil {
+		t.Fatal(err)
+	}
+	text := mustache.New(mustache.Name("text"), mustache.Escape(mustache.EscapeNone))
+	if err := text.ParseString("<{{name}}>"); err != nil {
+		t.Fatal(err)
+	}
+	data := func(r *http.Request) (interface{}, error) {
+		switch name := r.URL.Query().Get("name"); name {
+		case "":
+			return nil, errors.New("no name")
+		case "-":
+			return map[string]string{}, nil
+		default:
+			return map[string]string{"name": name}, nil
+		}
+	}
+	errorLog := log.New(ioutil.Discard, "", 0)
+	for _, test := range []struct {
+		handler     http.Handler
+		url         string
+		ifNoneMatch string
+		code        int
+		contentType string
+		body        string
+	}{
+		{Handler(page, data, ErrorLog(errorLog)), "/?name=<world>", "", 200, "text/html; charset=utf-8", "Hello, &lt;world&gt;!"},
+		{Handler(page, data, ErrorLog(errorLog)), "/", "", 500, "text/plain; charset=utf-8", "Internal Server Error\n"},
+		{Handler(page, data, ErrorLog(errorLog)), "/?name=-", "", 500, "text/plain; charset=utf-8", "Internal Server Error\n"},
+		{SetHandler(Templates{"text": text}, "text", data), "/?name=<world>", "", 200, "text/plain; charset=utf-8", "<<world>>"},
+		{SetHandler(Templates{}, "text", data, ErrorLog(errorLog)), "/?name=world", "", 500, "text/plain; charset=utf-8", "Internal Server Error\n"},
+		{Handler(page, data, ContentType("text/x-greeting")), "/?name=world", "", 200, "text/x-greeting", "Hello, world!"},
+		{Handler(page, data, ETag()), "/?name=world", `"abc", "315f5bdb76d078c43b8ac0064e4a0164"`, 304, "", ""},
+		{Handler(page, data, ETag()), "/?name=world", `"abc"`, 200, "text/html; charset=utf-8", "Hello, world!"},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.url, test.code, w.Code)
+		}
+		if s := w.Header().Get("Content-Type"); s != test.contentType {
+			t.Errorf("%s: expected content type %q, got %q", test.url, test.contentType, s)
+		}
+		if s := w.Body.String(); s != test.body {
+			t.Errorf("%s: expected body %q, got %q", test.url, test.body, s)
+		}
+	}
+}
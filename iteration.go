@@ -0,0 +1,51 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import "reflect"
+
+// Iteration exposes the metadata of the current iteration to the sections
+// iterating over lists, such as to separate items with commas:
+//
+//	{{#items}}{{name}}{{^@last}}, {{/@last}}{{/items}}
+//
+// The metadata is looked up after the current item and before the rest of the
+// context, using the following names:
+//
+//	@index  the index of the item, counted from 0
+//	@number the number of the item, counted from 1
+//	@first  true for the first item
+//	@last   true for the last item
+//	@length the number of items
+//
+// Note that like any zero number, an @index of 0 is falsy.
+func Iteration(enabled bool) Option {
+	return func(t *Template) {
+		t.iteration = enabled
+	}
+}
+
+// The iteration type is the context layer holding the metadata of the current
+// iteration of a section over a list.
+type iteration map[string]interface{}
+
+func newIteration(i, n int) iteration {
+	return iteration{
+		"@index":  i,
+		"@number": i + 1,
+		"@first":  i == 0,
+		"@last":   i == n-1,
+		"@length": n,
+	}
+}
+
+// iterationTypes are the types of the iteration metadata, for Check.
+var iterationTypes = map[string]reflect.Type{
+	"@index":  reflect.TypeOf(0),
+	"@number": reflect.TypeOf(0),
+	"@first":  reflect.TypeOf(false),
+	"@last":   reflect.TypeOf(false),
+	"@length": reflect.TypeOf(0),
+}
+
+var iterationType = reflect.TypeOf(iteration(nil))
@@ -0,0 +1,56 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIteration(t *testing.T) {
+	context := map[string]interface{}{
+		"items":  []map[string]string{{"name": "a"}, {"name": "b"}, {"name": "c"}},
+		"matrix": [][]int{{1, 2}, {3}},
+	}
+	for _, test := range []struct {
+		template string
+		expected string
+	}{
+		{"{{#items}}{{name}}{{^@last}}, {{/@last}}{{/items}}", "a, b, c"},
+		{"{{#items}}{{@number}}/{{@length}}:{{@index}}{{#@first}}!{{/@first}} {{/items}}", "1/3:0! 2/3:1 3/3:2 "},
+		{"{{#matrix}}[{{#.}}{{@index}}{{/.}}]{{#@last}}.{{/@last}}{{/matrix}}", "[01][0]."},
+		{"{{@index}}{{#items}}{{/items}}", ""},
+	} {
+		template := New(Iteration(true))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(context)
+		if err != nil {
+			t.Error(err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected %q got %q", test.template, test.expected, output)
+		}
+	}
+	// Without the option, the metadata isn't available.
+	template := New()
+	if err := template.ParseString("{{#items}}{{name}}{{^@last}}, {{/@last}}{{/items}}"); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := template.RenderString(context); output != "a, b, c, " {
+		t.Errorf("expected %q got %q", "a, b, c, ", output)
+	}
+}
+
+func TestCheckIteration(t *testing.T) {
+	template := New(Iteration(true))
+	if err := template.ParseString("{{#Items}}{{Name}}{{@index}}{{^@last}},{{/@last}}{{/Items}}{{@first}}"); err != nil {
+		t.Fatal(err)
+	}
+	err := template.Check(reflect.TypeOf(checkPage{}))
+	errs, ok := err.(CheckErrors)
+	if !ok || len(errs) != 1 || errs[0].Name != "@first" {
+		t.Errorf("expected an error for @first only, got %v", err)
+	}
+}
@@ -45,6 +45,8 @@ const (
 	tokenSetDelim       // {{={% %}=}} sets delimiters to {% and %}
 	tokenSetLeftDelim   // denotes a custom left delimiter
 	tokenSetRightDelim  // denotes a custom right delimiter
+	tokenPipe           // | separates the filters of {{name | upper}}
+	tokenString         // "quoted" filter argument
 )
 
 // Make the types prettyprint.
@@ -66,6 +68,8 @@ var tokenName = map[tokenType]string{
 	tokenSetDelim:       "t_set_delim",
 	tokenSetLeftDelim:   "t_set_left_delim",
 	tokenSetRightDelim:  "t_set_right_delim",
+	tokenPipe:           "t_pipe",
+	tokenString:         "t_string",
 }
 
 // String satisfies the fmt.Stringer interface making it easier to print tokens.
@@ -94,6 +98,9 @@ type lexer struct {
 	start      int        // start position of this token.
 	width      int        // width of last rune read from input.
 	tokens     chan token // channel of scanned tokens.
+	mark       int        // offset of the last reported position.
+	line, col  int        // line and column of mark, counted from 0.
+	filters    bool       // whether the filter extension is enabled.
 }
 
 // next returns the next rune in the input.
@@ -124,14 +131,11 @@ func (l *lexer) backup() {
 	l.pos -= l.width
 }
 
-// emit passes an token back to the client.
+// emit passes an token back to the client. The token is positioned at the
+// start of its value.
 func (l *lexer) emit(t tokenType) {
-	l.tokens <- token{
-		t,
-		l.input[l.start:l.pos],
-		l.lineNum(),
-		l.columnNum(),
-	}
+	line, col := l.position(l.start)
+	l.tokens <- token{t, l.input[l.start:l.pos], line, col}
 	l.start = l.pos
 }
 
@@ -140,29 +144,30 @@ func (l *lexer) ignore() {
 	l.start = l.pos
 }
 
-// lineNum reports which line we're on. Doing it this way
-// means we don't have to worry about peek double counting.
-func (l *lexer) lineNum() int {
-	return 1 + strings.Count(l.input[:l.pos], "\n")
-}
-
-// columnNum reports the character of the current line we're on.
-func (l *lexer) columnNum() int {
-	if lf := strings.LastIndex(l.input[:l.pos], "\n"); lf != -1 {
-		return len(l.input[lf+1 : l.pos])
+// position reports the line and column of the offset pos, counted from 1.
+// Positions are reported in increasing order, so only the input scanned since
+// the previous position is counted. Doing it this way means we don't have to
+// worry about peek double counting.
+func (l *lexer) position(pos int) (line, col int) {
+	if pos < l.mark {
+		l.mark, l.line, l.col = 0, 0, 0
+	}
+	s := l.input[l.mark:pos]
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		l.line += strings.Count(s, "\n")
+		l.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		l.col += utf8.RuneCountInString(s)
 	}
-	return len(l.input[:l.pos])
+	l.mark = pos
+	return l.line + 1, l.col + 1
 }
 
 // error returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating l.token.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.tokens <- token{
-		tokenError,
-		fmt.Sprintf(format, args...),
-		l.lineNum(),
-		l.columnNum(),
-	}
+	line, col := l.position(l.pos)
+	l.tokens <- token{tokenError, fmt.Sprintf(format, args...), line, col}
 	return nil
 }
 
@@ -277,10 +282,15 @@ func stateTag(l *lexer) stateFn {
 		l.emit(tokenRawAlt)
 	case r == '>':
 		l.emit(tokenPartial)
+		return statePartial
 	case r == '{':
 		l.emit(tokenRawStart)
-	case alphanum(r):
-		l.backup()
+	case r == '|' && l.filters:
+		l.emit(tokenPipe)
+	case r == '"' && l.filters:
+		return stateString
+	case alphanum(r) || r == '@':
+		// Names starting with @ refer to the metadata of section iterations.
 		return stateIdent
 	default:
 		return l.errorf("unrecognized character in action: %#U", r)
@@ -288,13 +298,15 @@ func stateTag(l *lexer) stateFn {
 	return stateTag
 }
 
-// stateIdent scans an alphanumeric or field.
+// stateIdent scans an alphanumeric or field. A minus sign may only start a
+// segment of a dotted name, such as the negative index of items.-1.
 func stateIdent(l *lexer) stateFn {
+	prev, _ := utf8.DecodeLastRuneInString(l.input[l.start:l.pos])
 Loop:
 	for {
 		switch r := l.next(); {
-		case alphanum(r):
-			// absorb.
+		case alphanum(r) || r == '-' && prev == '.':
+			prev = r
 		default:
 			l.backup()
 			l.emit(tokenIdentifier)
@@ -304,6 +316,47 @@ Loop:
 	return stateTag
 }
 
+// statePartial scans the name of a partial. Partial names may contain any
+// character other than whitespace, such as the slashes of "pages/home".
+func statePartial(l *lexer) stateFn {
+	for r := l.next(); r == ' ' || r == '\t'; r = l.next() {
+		l.ignore()
+	}
+	l.backup()
+	for {
+		if strings.HasPrefix(l.input[l.pos:], l.rightDelim) {
+			break
+		}
+		if r := l.next(); r == eof || whitespace(r) {
+			l.backup()
+			break
+		}
+	}
+	if l.pos > l.start {
+		l.emit(tokenIdentifier)
+	}
+	return stateTag
+}
+
+// stateString scans a quoted filter argument. The opening quote is known to be
+// present.
+func stateString(l *lexer) stateFn {
+	for {
+		switch l.next() {
+		case '\\':
+			if r := l.next(); r != eof && r != '\n' {
+				break
+			}
+			fallthrough
+		case eof, '\n':
+			return l.errorf("unterminated quoted string")
+		case '"':
+			l.emit(tokenString)
+			return stateTag
+		}
+	}
+}
+
 // stateComment scans a comment. The left comment marker is known to be present.
 func stateComment(l *lexer) stateFn {
 	i := strings.Index(l.input[l.pos:], l.rightDelim)
@@ -335,9 +388,13 @@ func stateSetDelim(l *lexer) stateFn {
 			}
 		}
 	}
+	// The token's value holds the new delimiters separated by a space, so
+	// that the parser is able to keep track of them.
+	value := strings.Join(strings.Fields(l.input[l.pos:l.pos+i]), " ")
+	line, col := l.position(l.start)
+	l.tokens <- token{tokenSetDelim, value, line, col}
 	l.seek(i + len(end))
 	l.ignore()
-	l.emit(tokenSetDelim)
 	return stateText
 }
 
@@ -34,13 +34,13 @@ func TestLexer(t *testing.T) {
 				{typ: tokenIdentifier, val: "bar"},
 				{typ: tokenRightDelim, val: "}}"},
 				{typ: tokenText, val: " baz "},
-				{typ: tokenSetDelim},
+				{typ: tokenSetDelim, val: "| |"},
 				{typ: tokenText, val: "\r\n "},
 				{typ: tokenLeftDelim, val: "|"},
 				{typ: tokenIdentifier, val: "foo"},
 				{typ: tokenRightDelim, val: "|"},
 				{typ: tokenText, val: " "},
-				{typ: tokenSetDelim},
+				{typ: tokenSetDelim, val: "{{ }}"},
 				{typ: tokenText, val: " "},
 				{typ: tokenLeftDelim, val: "{{"},
 				{typ: tokenIdentifier, val: "bar"},
@@ -48,6 +48,29 @@ func TestLexer(t *testing.T) {
 				{typ: tokenEOF},
 			},
 		},
+		{
+			"{{> pages/home }}{{>a-b}}",
+			[]token{
+				{typ: tokenLeftDelim, val: "{{"},
+				{typ: tokenPartial, val: ">"},
+				{typ: tokenIdentifier, val: "pages/home"},
+				{typ: tokenRightDelim, val: "}}"},
+				{typ: tokenLeftDelim, val: "{{"},
+				{typ: tokenPartial, val: ">"},
+				{typ: tokenIdentifier, val: "a-b"},
+				{typ: tokenRightDelim, val: "}}"},
+				{typ: tokenEOF},
+			},
+		},
+		{
+			"{{items.-1.name}}",
+			[]token{
+				{typ: tokenLeftDelim, val: "{{"},
+				{typ: tokenIdentifier, val: "items.-1.name"},
+				{typ: tokenRightDelim, val: "}}"},
+				{typ: tokenEOF},
+			},
+		},
 	} {
 		var (
 			lexer = newLexer(test.template, "{{", "}}")
@@ -0,0 +1,178 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"time"
+)
+
+// The Limit type identifies a limit on the rendering of templates.
+type Limit int
+
+const (
+	// LimitOutput is the limit set by MaxOutput.
+	LimitOutput Limit = iota
+	// LimitDepth is the limit set by MaxDepth.
+	LimitDepth
+	// LimitIterations is the limit set by MaxIterations.
+	LimitIterations
+	// LimitTime is the limit set by MaxRenderTime.
+	LimitTime
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitOutput:
+		return "output size"
+	case LimitDepth:
+		return "partial depth"
+	case LimitIterations:
+		return "section iterations"
+	case LimitTime:
+		return "render time"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+// The LimitError type is returned when rendering a template exceeds one of its
+// limits. Rendering stops as soon as a limit is exceeded, but some of the
+// output may have been written already.
+type LimitError struct {
+	Template string // name of the rendered template
+	Limit    Limit
+	Max      int64 // value of the limit, in nanoseconds for LimitTime
+}
+
+func (e *LimitError) Error() string {
+	max := fmt.Sprint(e.Max)
+	if e.Limit == LimitTime {
+		max = time.Duration(e.Max).String()
+	}
+	if e.Template != "" {
+		return fmt.Sprintf("%s: exceeded the maximum %s of %s", e.Template, e.Limit, max)
+	}
+	return fmt.Sprintf("exceeded the maximum %s of %s", e.Limit, max)
+}
+
+// MaxOutput limits the output of the template to n bytes.
+func MaxOutput(n int) Option {
+	return func(t *Template) {
+		t.limits.Output = n
+	}
+}
+
+// MaxDepth limits the depth of nested partials to n, so that templates with
+// recursive partials can't render indefinitely.
+func MaxDepth(n int) Option {
+	return func(t *Template) {
+		t.limits.Depth = n
+	}
+}
+
+// MaxIterations limits the number of list items iterated over by all the
+// sections of the template to n.
+func MaxIterations(n int) Option {
+	return func(t *Template) {
+		t.limits.Iterations = n
+	}
+}
+
+// MaxRenderTime limits the time spent rendering the template to d.
+func MaxRenderTime(d time.Duration) Option {
+	return func(t *Template) {
+		t.limits.Time = d
+	}
+}
+
+// The limits type holds the limits of a template. Zero values mean there is no
+// limit. The limits of the rendered template apply to its partials as well.
+type limits struct {
+	Output     int
+	Depth      int
+	Iterations int
+	Time       time.Duration
+}
+
+// The limiter type enforces limits during a single rendering.
+type limiter struct {
+	limits
+	template   string
+	output     int
+	depth      int
+	iterations int
+	deadline   time.Time
+	err        error // the first limit exceeded
+}
+
+func newLimiter(t *Template) *limiter {
+	l := &limiter{limits: t.limits, template: t.name}
+	if l.limits.Time > 0 {
+		l.deadline = time.Now().Add(l.limits.Time)
+	}
+	return l
+}
+
+func (l *limiter) exceed(limit Limit, max int64) error {
+	if l.err == nil {
+		l.err = &LimitError{Template: l.template, Limit: limit, Max: max}
+	}
+	return l.err
+}
+
+// check returns an error if a limit was exceeded, or the render time is over.
+func (l *limiter) check() error {
+	if l == nil {
+		return nil
+	}
+	if l.err != nil {
+		return l.err
+	}
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return l.exceed(LimitTime, int64(l.limits.Time))
+	}
+	return nil
+}
+
+// write accounts for n bytes of output.
+func (l *limiter) write(n int) error {
+	if l == nil {
+		return nil
+	}
+	l.output += n
+	if l.limits.Output > 0 && l.output > l.limits.Output {
+		return l.exceed(LimitOutput, int64(l.limits.Output))
+	}
+	return l.err
+}
+
+// iterate accounts for an iteration of a section over a list item.
+func (l *limiter) iterate() error {
+	if l == nil {
+		return nil
+	}
+	l.iterations++
+	if l.limits.Iterations > 0 && l.iterations > l.limits.Iterations {
+		return l.exceed(LimitIterations, int64(l.limits.Iterations))
+	}
+	return l.check()
+}
+
+// enter accounts for entering a partial. It must be followed by a call to
+// leave once the partial is rendered.
+func (l *limiter) enter() error {
+	if l == nil {
+		return nil
+	}
+	l.depth++
+	if l.limits.Depth > 0 && l.depth > l.limits.Depth {
+		return l.exceed(LimitDepth, int64(l.limits.Depth))
+	}
+	return l.check()
+}
+
+func (l *limiter) leave() {
+	if l != nil {
+		l.depth--
+	}
+}
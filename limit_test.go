@@ -0,0 +1,66 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	node := New(Name("node"))
+	if err := node.ParseString("<{{#items}}{{>node}}{{/items}}>"); err != nil {
+		t.Fatal(err)
+	}
+	items := make([]int, 1000)
+	for _, test := range []struct {
+		template string
+		options  []Option
+		context  interface{}
+		limit    Limit
+		output   string
+	}{
+		{"{{#items}}x{{/items}}", []Option{MaxOutput(10)}, map[string][]int{"items": items}, LimitOutput, ""},
+		{"{{#items}}{{.}}{{/items}}", []Option{MaxIterations(3)}, map[string][]int{"items": items}, LimitIterations, ""},
+		{"{{>node}}", []Option{MaxDepth(3)}, map[string][]int{"items": items}, LimitDepth, ""},
+		{"{{#items}}x{{/items}}", []Option{MaxRenderTime(time.Nanosecond)}, map[string][]int{"items": items}, LimitTime, ""},
+		{"{{#items}}x{{/items}}\n", []Option{MaxOutput(1001), MaxIterations(1000), MaxDepth(1)}, map[string][]int{"items": items}, 0, strings.Repeat("x", 1000) + "\n"},
+	} {
+		template := New(append(test.options, Partial(node))...)
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(test.context)
+		if test.output != "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", test.template, err)
+			} else if output != test.output {
+				t.Errorf("%s: expected %q, got %q", test.template, test.output, output)
+			}
+			continue
+		}
+		e, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("%s: expected a limit error, got %v", test.template, err)
+			continue
+		}
+		if e.Limit != test.limit {
+			t.Errorf("%s: expected the %s limit, got %s", test.template, test.limit, e.Limit)
+		}
+	}
+}
+
+func TestLimitError(t *testing.T) {
+	for _, test := range []struct {
+		err      *LimitError
+		expected string
+	}{
+		{&LimitError{Limit: LimitOutput, Max: 10}, "exceeded the maximum output size of 10"},
+		{&LimitError{Template: "page", Limit: LimitTime, Max: int64(time.Second)}, "page: exceeded the maximum render time of 1s"},
+	} {
+		if s := test.err.Error(); s != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, s)
+		}
+	}
+}
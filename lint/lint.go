@@ -0,0 +1,248 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+// Package lint reports suspicious constructs in mustache templates.
+//
+// Each check is implemented by a Rule which may be disabled individually using
+// the Config given to Lint.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alexkappa/mustache"
+	"github.com/alexkappa/mustache/ast"
+)
+
+// The Rule type describes a check performed by Lint.
+type Rule struct {
+	Name string // name used to enable or disable the rule
+	Doc  string // short description of the rule
+}
+
+// Rules performed by Lint.
+var (
+	Unclosed  = &Rule{"unclosed", "sections and tags which are never closed"}
+	Mismatch  = &Rule{"mismatch", "sections closed with a different name than opened"}
+	Delims    = &Rule{"delims", "set delimiter tags which are never used"}
+	Unescaped = &Rule{"unescaped", "unescaped variables, such as {{{name}}}, in HTML templates"}
+	Partials  = &Rule{"partials", "partials which don't exist"}
+	Depth     = &Rule{"depth", "sections nested too deeply"}
+	Syntax    = &Rule{"syntax", "templates which fail to parse"}
+)
+
+// Rules lists every rule performed by Lint.
+var Rules = []*Rule{Unclosed, Mismatch, Delims, Unescaped, Partials, Depth, Syntax}
+
+// The Config type configures Lint. The zero value performs every rule except
+// for Unescaped and Partials, which need to be configured.
+type Config struct {
+	// Disabled holds the names of the rules which are not performed.
+	Disabled map[string]bool
+
+	// HTML enables the Unescaped rule, as the template produces HTML.
+	HTML bool
+
+	// Partial reports whether a partial named name exists. If nil, the
+	// Partials rule is not performed.
+	Partial func(name string) bool
+
+	// MaxDepth is the maximum nesting of sections before the Depth rule
+	// reports them. If zero, DefaultMaxDepth is used.
+	MaxDepth int
+}
+
+// DefaultMaxDepth is the default maximum nesting of sections.
+const DefaultMaxDepth = 4
+
+// The Diagnostic type describes a problem found by Lint.
+type Diagnostic struct {
+	File    string  // name of the template file
+	Pos     ast.Pos // position of the problem, if known
+	Rule    *Rule   // rule reporting the problem
+	Message string
+}
+
+// String returns the diagnostic in the form "file:line:col: message (rule)"
+// understood by most editors.
+func (d Diagnostic) String() string {
+	if d.Pos.IsValid() {
+		return fmt.Sprintf("%s:%s: %s (%s)", d.File, d.Pos, d.Message, d.Rule.Name)
+	}
+	return fmt.Sprintf("%s: %s (%s)", d.File, d.Message, d.Rule.Name)
+}
+
+// Lint checks the template src read from file and returns the problems found,
+// ordered by position. If c is nil the zero Config is used.
+func Lint(file string, src []byte, c *Config) []Diagnostic {
+	if c == nil {
+		c = &Config{}
+	}
+	l := &linter{file: file, src: string(src), config: c}
+	l.scan()
+	t := mustache.New()
+	if err := t.ParseBytes(src); err != nil {
+		// Problems with the structure of the template are better described
+		// by the scan, so the parse error is reported only if no enabled rule
+		// found any.
+		if l.found == 0 {
+			if e, ok := err.(*mustache.ParseError); ok {
+				l.report(Syntax, ast.Pos{Line: e.Line, Col: e.Col}, "%s", e.Msg)
+			} else {
+				l.report(Syntax, ast.Pos{}, "%s", err)
+			}
+		}
+	} else {
+		l.tree(t.Tree().Nodes, 0)
+	}
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		a, b := l.diags[i].Pos, l.diags[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+	return l.diags
+}
+
+// The linter type holds the state of Lint.
+type linter struct {
+	file   string
+	src    string
+	config *Config
+	diags  []Diagnostic
+	found  int // number of problems reported by enabled rules
+}
+
+func (l *linter) report(r *Rule, pos ast.Pos, format string, v ...interface{}) {
+	if l.config.Disabled[r.Name] {
+		return
+	}
+	l.found++
+	l.diags = append(l.diags, Diagnostic{l.file, pos, r, fmt.Sprintf(format, v...)})
+}
+
+// pos returns the position of the byte offset i in the source.
+func (l *linter) pos(i int) ast.Pos {
+	line := 1 + strings.Count(l.src[:i], "\n")
+	start := strings.LastIndex(l.src[:i], "\n") + 1
+	return ast.Pos{Line: line, Col: 1 + utf8.RuneCountInString(l.src[start:i])}
+}
+
+// The openTag type is a section opening tag found by scan.
+type openTag struct {
+	name string
+	pos  ast.Pos
+}
+
+// scan checks the structure of the template by scanning its tags. Unlike the
+// parser, it continues after a problem is found so that all of them can be
+// reported at once.
+func (l *linter) scan() {
+	var (
+		left, right = "{{", "}}"
+		stack       []openTag
+		delim       *ast.Pos // position of the last set delimiter tag
+		used        = true   // whether a tag followed the last set delimiter tag
+	)
+	unused := func() {
+		if !used {
+			l.report(Delims, *delim, "delimiters are changed but never used")
+		}
+	}
+	for i := 0; ; {
+		j := strings.Index(l.src[i:], left)
+		if j < 0 {
+			break
+		}
+		start := i + j
+		pos := l.pos(start)
+		inner := start + len(left)
+		end := right
+		if strings.HasPrefix(l.src[inner:], "{") {
+			end = "}" + right
+		}
+		k := strings.Index(l.src[inner:], end)
+		if k < 0 {
+			l.report(Unclosed, pos, "tag is never closed")
+			break
+		}
+		content := strings.TrimSpace(l.src[inner : inner+k])
+		i = inner + k + len(end)
+		if strings.HasPrefix(content, "=") && strings.HasSuffix(content, "=") && len(content) > 1 {
+			unused()
+			d := strings.Fields(content[1 : len(content)-1])
+			if len(d) == 2 {
+				left, right = d[0], d[1]
+			}
+			delim, used = &pos, false
+			continue
+		}
+		used = true
+		if content == "" {
+			continue
+		}
+		name := strings.TrimSpace(content[1:])
+		switch content[0] {
+		case '#', '^':
+			stack = append(stack, openTag{name, pos})
+		case '/':
+			stack = l.close(stack, name, pos)
+		}
+	}
+	unused()
+	for _, open := range stack {
+		l.report(Unclosed, open.pos, "section %s is never closed", open.name)
+	}
+}
+
+// close matches the closing tag of section name found at pos with the
+// sections opened in stack and returns the sections which remain open.
+func (l *linter) close(stack []openTag, name string, pos ast.Pos) []openTag {
+	if len(stack) == 0 {
+		l.report(Mismatch, pos, "closing tag /%s without an opening tag", name)
+		return stack
+	}
+	top := stack[len(stack)-1]
+	if top.name == name {
+		return stack[:len(stack)-1]
+	}
+	l.report(Mismatch, pos, "section %s opened at %s closed by /%s", top.name, top.pos, name)
+	// If the name matches a section opened earlier, the sections opened
+	// after it are assumed to be unclosed.
+	for i := len(stack) - 2; i >= 0; i-- {
+		if stack[i].name == name {
+			for _, open := range stack[i+1:] {
+				if open != top {
+					l.report(Unclosed, open.pos, "section %s is never closed", open.name)
+				}
+			}
+			return stack[:i]
+		}
+	}
+	return stack[:len(stack)-1]
+}
+
+// tree checks the parse tree of the template.
+func (l *linter) tree(nodes []ast.Node, depth int) {
+	max := l.config.MaxDepth
+	if max == 0 {
+		max = DefaultMaxDepth
+	}
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Var:
+			if l.config.HTML && !n.Escaped {
+				l.report(Unescaped, n.Start, "variable %s is not HTML escaped", n.Name)
+			}
+		case *ast.Partial:
+			if l.config.Partial != nil && !l.config.Partial(n.Name) {
+				l.report(Partials, n.Start, "partial %s does not exist", n.Name)
+			}
+		case *ast.Section:
+			if depth == max {
+				l.report(Depth, n.Start, "section %s is nested deeper than %d levels", n.Name, max)
+			}
+			l.tree(n.Nodes, depth+1)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package lint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	exists := func(name string) bool { return name == "header" }
+	for _, test := range []struct {
+		template string
+		config   *Config
+		expected []string
+	}{
+		{"{{#a}}{{b}}{{/a}}", nil, nil},
+		{
+			"{{#a}}\n{{#b}}{{/a}}{{/b}}",
+			nil,
+			[]string{
+				"t.mustache:2:7: section b opened at 2:1 closed by /a (mismatch)",
+				"t.mustache:2:13: closing tag /b without an opening tag (mismatch)",
+			},
+		},
+		{
+			"{{#a}}{{/b}}",
+			nil,
+			[]string{
+				"t.mustache:1:7: section a opened at 1:1 closed by /b (mismatch)",
+			},
+		},
+		{
+			"{{#a}}{{#b}}{{^c}}",
+			nil,
+			[]string{
+				"t.mustache:1:1: section a is never closed (unclosed)",
+				"t.mustache:1:7: section b is never closed (unclosed)",
+				"t.mustache:1:13: section c is never closed (unclosed)",
+			},
+		},
+		{"text {{name", nil, []string{"t.mustache:1:6: tag is never closed (unclosed)"}},
+		{
+			"{{=<% %>=}}\n<%={{ }}=%>{{a}}{{=| |=}}",
+			nil,
+			[]string{
+				"t.mustache:1:1: delimiters are changed but never used (delims)",
+				"t.mustache:2:17: delimiters are changed but never used (delims)",
+			},
+		},
+		{"{{=<% %>=}}<%a%>", nil, nil},
+		{
+			"{{{a}}} {{&b}} {{c}}",
+			&Config{HTML: true},
+			[]string{
+				"t.mustache:1:1: variable a is not HTML escaped (unescaped)",
+				"t.mustache:1:9: variable b is not HTML escaped (unescaped)",
+			},
+		},
+		{"{{{a}}}", nil, nil},
+		{
+			"{{>header}}{{>footer}}",
+			&Config{Partial: exists},
+			[]string{"t.mustache:1:12: partial footer does not exist (partials)"},
+		},
+		{
+			"{{#a}}{{#b}}{{#c}}{{#d}}{{/d}}{{/c}}{{/b}}{{/a}}",
+			&Config{MaxDepth: 2},
+			[]string{"t.mustache:1:13: section c is nested deeper than 2 levels (depth)"},
+		},
+		{
+			"{{#a}}{{/b}}{{{x}}}",
+			&Config{HTML: true, Disabled: map[string]bool{"mismatch": true}},
+			[]string{"t.mustache:1:7: section a opened at 1:1 closed by /b at 1:7 (syntax)"},
+		},
+	} {
+		var diags []string
+		for _, d := range Lint("t.mustache", []byte(test.template), test.config) {
+			diags = append(diags, d.String())
+		}
+		if !reflect.DeepEqual(diags, test.expected) {
+			t.Errorf("%q: unexpected diagnostics %q, expected %q", test.template, diags, test.expected)
+		}
+	}
+}
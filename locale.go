@@ -0,0 +1,179 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Money type is an amount of money in a currency, identified by its ISO
+// 4217 code such as "EUR". With the Locale option, amounts are formatted
+// following the conventions of the locale, such as 1.234,50 € in de-DE.
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+// String formats the amount with the number of decimals of its currency,
+// followed by its code, such as 1234.50 EUR.
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Amount, 'f', currencyDigits(m.Currency), 64) + " " + m.Currency
+}
+
+// The locale type holds the conventions of a locale for formatting values.
+type locale struct {
+	group   string // separator of groups of thousands
+	decimal string // decimal mark
+	money   string // pattern of amounts, where # is the number and ¤ the symbol
+	date    string // layout of dates
+}
+
+// locales are the locales known to the Locale option, by tag.
+var locales = map[string]locale{
+	"en-US": {",", ".", "¤#", "01/02/2006"},
+	"en-GB": {",", ".", "¤#", "02/01/2006"},
+	"de-DE": {".", ",", "# ¤", "02.01.2006"},
+	"de-AT": {" ", ",", "¤ #", "02.01.2006"},
+	"de-CH": {"’", ".", "¤ #", "02.01.2006"},
+	"fr-FR": {" ", ",", "# ¤", "02/01/2006"},
+	"es-ES": {".", ",", "# ¤", "02/01/2006"},
+	"it-IT": {".", ",", "# ¤", "02/01/2006"},
+	"nl-NL": {".", ",", "¤ #", "02-01-2006"},
+	"pt-BR": {".", ",", "¤ #", "02/01/2006"},
+	"sv-SE": {" ", ",", "# ¤", "2006-01-02"},
+	"ja-JP": {",", ".", "¤#", "2006/01/02"},
+}
+
+// currencySymbols are the symbols of common currencies. Other currencies are
+// formatted using their code.
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+	"JPY": "¥",
+	"BRL": "R$",
+	"SEK": "kr",
+}
+
+// currencyDigits returns the number of decimals amounts of currency have.
+func currencyDigits(currency string) int {
+	switch currency {
+	case "JPY", "KRW", "ISK":
+		return 0
+	}
+	return 2
+}
+
+// Locale formats numbers, Money amounts and dates following the conventions
+// of the locale identified by tag, such as "de-DE" or "en-US". Numbers are
+// formatted with the grouping separator and decimal mark of the locale, and
+// dates, as time.Time values, in its numeric short form. If the tag isn't
+// known, a locale of the same language is used if there is one, otherwise the
+// option has no effect on formatting. Formatters for the same types set by
+// FormatType after this option take precedence.
+//
+// The locale also selects the plural rules and, unless the Translate option
+// sets another one, the messages of translated sections.
+//
+// The known locales are de-AT, de-CH, de-DE, en-GB, en-US, es-ES, fr-FR,
+// it-IT, ja-JP, nl-NL, pt-BR and sv-SE.
+func Locale(tag string) Option {
+	l, ok := findLocale(tag)
+	return func(t *Template) {
+		t.locale = tag
+		if !ok {
+			return
+		}
+		for _, v := range []interface{}{int(0), int8(0), int16(0), int32(0), int64(0)} {
+			FormatType(reflect.TypeOf(v), func(v interface{}) (string, error) {
+				return l.number(strconv.FormatInt(reflect.ValueOf(v).Int(), 10)), nil
+			})(t)
+		}
+		for _, v := range []interface{}{uint(0), uint8(0), uint16(0), uint32(0), uint64(0)} {
+			FormatType(reflect.TypeOf(v), func(v interface{}) (string, error) {
+				return l.number(strconv.FormatUint(reflect.ValueOf(v).Uint(), 10)), nil
+			})(t)
+		}
+		FormatType(reflect.TypeOf(float32(0)), func(v interface{}) (string, error) {
+			return l.float(float64(v.(float32)), 32)
+		})(t)
+		FormatType(reflect.TypeOf(float64(0)), func(v interface{}) (string, error) {
+			return l.float(v.(float64), 64)
+		})(t)
+		FormatType(reflect.TypeOf(Money{}), func(v interface{}) (string, error) {
+			return l.formatMoney(v.(Money))
+		})(t)
+		FormatType(reflect.TypeOf(time.Time{}), func(v interface{}) (string, error) {
+			return v.(time.Time).Format(l.date), nil
+		})(t)
+	}
+}
+
+// findLocale returns the locale identified by tag, or else the first locale,
+// in alphabetical order of tags, of the same language.
+func findLocale(tag string) (locale, bool) {
+	tag = strings.Replace(tag, "_", "-", -1)
+	lang := strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	var fallback string
+	for t := range locales {
+		if strings.EqualFold(t, tag) {
+			return locales[t], true
+		}
+		if strings.HasPrefix(t, lang+"-") && (fallback == "" || t < fallback) {
+			fallback = t
+		}
+	}
+	l, ok := locales[fallback]
+	return l, ok
+}
+
+// number formats the decimal number s, as formatted by the strconv package,
+// using the separators of the locale.
+func (l locale) number(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	frac := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, frac = s[:i], l.decimal+s[i+1:]
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(frac)
+	return b.String()
+}
+
+func (l locale) float(f float64, bitSize int) (string, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, bitSize), nil
+	}
+	return l.number(strconv.FormatFloat(f, 'f', -1, bitSize)), nil
+}
+
+func (l locale) formatMoney(m Money) (string, error) {
+	if math.IsInf(m.Amount, 0) || math.IsNaN(m.Amount) {
+		return "", fmt.Errorf("invalid amount %v", m.Amount)
+	}
+	symbol, ok := currencySymbols[m.Currency]
+	if !ok {
+		symbol = m.Currency
+	}
+	s := l.number(strconv.FormatFloat(math.Abs(m.Amount), 'f', currencyDigits(m.Currency), 64))
+	s = strings.Replace(strings.Replace(l.money, "#", s, 1), "¤", symbol, 1)
+	if m.Amount < 0 {
+		s = "-" + s
+	}
+	return s, nil
+}
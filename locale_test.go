@@ -0,0 +1,48 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocale(t *testing.T) {
+	context := map[string]interface{}{
+		"total": Money{1234.5, "EUR"},
+		"debt":  Money{-1234567.891, "USD"},
+		"yen":   Money{1234.5, "JPY"},
+		"other": Money{10, "XYZ"},
+		"count": 1234567,
+		"small": int8(-12),
+		"ratio": 12345.678,
+		"when":  time.Date(2014, 3, 14, 15, 9, 26, 0, time.UTC),
+	}
+	for _, test := range []struct {
+		locale   string
+		template string
+		expected string
+	}{
+		{"de-DE", "{{total}}", "1.234,50 €"},
+		{"de-DE", "{{debt}} {{yen}} {{other}}", "-1.234.567,89 $ 1.234 ¥ 10,00 XYZ"},
+		{"de-DE", "{{count}} {{small}} {{ratio}} {{when}}", "1.234.567 -12 12.345,678 14.03.2014"},
+		{"en-US", "{{total}} {{debt}} {{count}} {{ratio}} {{when}}", "€1,234.50 -$1,234,567.89 1,234,567 12,345.678 03/14/2014"},
+		{"fr-FR", "{{total}} {{count}}", "1 234,50 € 1 234 567"},
+		{"de-CH", "{{total}} {{ratio}}", "€ 1’234.50 12’345.678"},
+		{"de_li", "{{count}}", "1 234 567"}, // falls back to de-AT
+		{"xx-XX", "{{total}} {{count}}", "1234.50 EUR 1234567"},
+		{"", "{{total}} {{ratio}}", "1234.50 EUR 12345.678"},
+	} {
+		template := New(Locale(test.locale))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(context)
+		if err != nil {
+			t.Error(err)
+		}
+		if output != test.expected {
+			t.Errorf("%s %s: expected %q got %q", test.locale, test.template, test.expected, output)
+		}
+	}
+}
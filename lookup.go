@@ -4,7 +4,9 @@
 package mustache
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -13,15 +15,34 @@ import (
 // is the most likely to have the value we're looking for. If not found, we'll
 // move up the chain and repeat.
 func lookup(name string, context ...interface{}) (interface{}, bool) {
+	v, ok, _ := lookupOptions{}.lookup(name, context...)
+	return v, ok
+}
+
+// The lookupOptions type holds the options of a template which alter lookups.
+type lookupOptions struct {
+	// methods reports which methods may be called. If nil, any method may be
+	// called. An attempt to call a method which is not allowed results in a
+	// *SandboxError.
+	methods MethodPolicy
+	// negativeIndex enables negative indices counting from the end of slices
+	// and arrays.
+	negativeIndex bool
+}
+
+// lookup searches for name within the context chain, like the lookup function,
+// but following the options of o.
+func (o lookupOptions) lookup(name string, context ...interface{}) (interface{}, bool, error) {
 	// If the dot notation was used we split the word in two and perform two
 	// consecutive lookups. If the first one fails we return no value and a
 	// negative truth. Taken from github.com/hoisie/mustache.
 	if name != "." && strings.Contains(name, ".") {
 		parts := strings.SplitN(name, ".", 2)
-		if value, ok := lookup(parts[0], context...); ok {
-			return lookup(parts[1], value)
+		value, ok, err := o.lookup(parts[0], context...)
+		if ok {
+			return o.lookup(parts[1], value)
 		}
-		return nil, false
+		return nil, false, err
 	}
 	// Iterate over the context chain and try to match the name to a value.
 	for _, c := range context {
@@ -30,7 +51,7 @@ func lookup(name string, context ...interface{}) (interface{}, bool) {
 		reflectType := reflect.TypeOf(c)
 		// If the name is ".", we should return the whole context as-is.
 		if name == "." {
-			return c, truth(reflectValue)
+			return c, truth(reflectValue), nil
 		}
 		switch reflectValue.Kind() {
 		// If the current context is a map, we'll look for a key in that map
@@ -40,9 +61,25 @@ func lookup(name string, context ...interface{}) (interface{}, bool) {
 			//
 			// 	m := map[string]string{"foo": "bar"}
 			//  mustache.Render("{{foo}}", m)
-			item := reflectValue.MapIndex(reflect.ValueOf(name))
-			if item.IsValid() {
-				return item.Interface(), truth(item)
+			//
+			// Names are converted to the key type of the map, so that maps
+			// with integer keys, keys of a named string type or interface
+			// keys match as well.
+			for _, key := range mapKeys(name, reflectType.Key()) {
+				item := reflectValue.MapIndex(key)
+				if item.IsValid() {
+					return item.Interface(), truth(item), nil
+				}
+			}
+		// If the current context is a slice or an array, we'll look for the
+		// item at the index given by the name. For example:
+		//
+		// 	m := map[string][]string{"foo": {"bar", "baz"}}
+		// 	mustache.Render("{{foo.1}}", m)
+		case reflect.Slice, reflect.Array:
+			if i, ok := index(name, reflectValue.Len(), o.negativeIndex); ok {
+				item := reflectValue.Index(i)
+				return item.Interface(), truth(item), nil
 			}
 		// If the current context is a struct, we'll look for a property in that
 		// struct that matches the name. In the near future I'd like to add
@@ -56,7 +93,7 @@ func lookup(name string, context ...interface{}) (interface{}, bool) {
 			//  mustache.Render("{{Bar}}", ctx)
 			field := reflectValue.FieldByName(name)
 			if field.IsValid() {
-				return field.Interface(), truth(field)
+				return field.Interface(), truth(field), nil
 			}
 			// If no field was matched, we'll try to match a method. This is
 			// useful for methods that return a value. For example:
@@ -67,9 +104,12 @@ func lookup(name string, context ...interface{}) (interface{}, bool) {
 			//  mustache.Render("{{Bar}}", ctx)
 			//
 			method := reflectValue.MethodByName(name)
-			if method.IsValid() && method.Type().NumIn() == 1 {
+			if method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() > 0 {
+				if o.methods != nil && !o.methods(reflectType, name) {
+					return nil, false, &SandboxError{Type: reflectType, Method: name}
+				}
 				out := method.Call(nil)[0]
-				return out.Interface(), truth(out)
+				return out.Interface(), truth(out), nil
 			}
 			// If no method was matched, we'll try to match a tag. This is
 			// useful for matching fields that have a different name than the
@@ -85,7 +125,7 @@ func lookup(name string, context ...interface{}) (interface{}, bool) {
 				field := reflectValue.Field(i)
 				tag := reflectType.Field(i).Tag.Get("template")
 				if tag == name {
-					return field.Interface(), truth(field)
+					return field.Interface(), truth(field), nil
 				}
 			}
 		}
@@ -94,7 +134,152 @@ func lookup(name string, context ...interface{}) (interface{}, bool) {
 	}
 	// We've exhausted the whole context chain and found nothing. Return a nil
 	// value and a negative truth.
-	return nil, false
+	return nil, false, nil
+}
+
+// The mapKeys function converts name to the values of the key type of a map
+// it may represent. Names are converted to strings, numbers and booleans, so
+// that maps with keys of named types match as well. For interface key types,
+// such as the map[interface{}]interface{} values produced by some decoders,
+// every value name may represent which satisfies the interface is returned.
+func mapKeys(name string, typ reflect.Type) []reflect.Value {
+	if typ.Kind() == reflect.Interface {
+		var keys []reflect.Value
+		for _, t := range []reflect.Type{stringType, intType, floatType, boolType} {
+			if !t.AssignableTo(typ) {
+				continue
+			}
+			if key, ok := mapKey(name, t); ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	if key, ok := mapKey(name, typ); ok {
+		return []reflect.Value{key}
+	}
+	return nil
+}
+
+var (
+	stringType = reflect.TypeOf("")
+	intType    = reflect.TypeOf(0)
+	floatType  = reflect.TypeOf(0.0)
+	boolType   = reflect.TypeOf(false)
+)
+
+// The mapKey function converts name to a value of typ. The conversion fails if
+// name doesn't represent a value of that type.
+func mapKey(name string, typ reflect.Type) (reflect.Value, bool) {
+	key := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.String:
+		key.SetString(name)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(name, 10, typ.Bits())
+		if err != nil {
+			return key, false
+		}
+		key.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(name, 10, typ.Bits())
+		if err != nil {
+			return key, false
+		}
+		key.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(name, typ.Bits())
+		if err != nil {
+			return key, false
+		}
+		key.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(name)
+		if err != nil {
+			return key, false
+		}
+		key.SetBool(b)
+	default:
+		return key, false
+	}
+	return key, true
+}
+
+// The index function converts name to an index of a slice or an array of
+// length n. If negative is true, negative indices count from the end.
+func index(name string, n int, negative bool) (int, bool) {
+	i, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, false
+	}
+	if i < 0 && negative {
+		i += n
+	}
+	return i, i >= 0 && i < n
+}
+
+// NegativeIndex enables negative indices in names, which count from the end of
+// slices and arrays, such as {{items.-1}} for the last item.
+func NegativeIndex(enabled bool) Option {
+	return func(t *Template) {
+		t.negativeIndex = enabled
+	}
+}
+
+// The MethodPolicy type reports whether the method named name of values of
+// type typ may be called when looking up names in a sandbox.
+type MethodPolicy func(typ reflect.Type, name string) bool
+
+// Sandbox restricts the methods of the context which may be called by the
+// template to the ones allowed by policy. If policy is nil, names are only
+// looked up in map keys and struct fields. Attempts to call other methods stop
+// the rendering with a *SandboxError, even if misses are silent. This includes
+// the String and Error methods of the values of variables, unless they are
+// formatted by a Formatter or FormatType function. Filters and formatters are
+// given values as they are, and may call their methods.
+func Sandbox(policy MethodPolicy) Option {
+	return func(t *Template) {
+		if policy == nil {
+			policy = func(reflect.Type, string) bool { return false }
+		}
+		t.methods = policy
+	}
+}
+
+// AllowMethods returns a policy which allows the listed methods, given as the
+// type and method names, such as "user.User.FullName".
+func AllowMethods(methods ...string) MethodPolicy {
+	allowed := make(map[string]bool)
+	for _, m := range methods {
+		allowed[m] = true
+	}
+	return func(typ reflect.Type, name string) bool {
+		return allowed[typ.String()+"."+name]
+	}
+}
+
+// AllowInterface returns a policy which allows the methods of types
+// implementing the interface pointed to by iface. It may be used with marker
+// interfaces to let types opt in, for example:
+//
+//	type TemplateSafe interface{ TemplateSafe() }
+//	template.Option(Sandbox(AllowInterface((*TemplateSafe)(nil))))
+func AllowInterface(iface interface{}) MethodPolicy {
+	i := reflect.TypeOf(iface).Elem()
+	return func(typ reflect.Type, name string) bool {
+		return typ.Implements(i)
+	}
+}
+
+// The SandboxError type is returned when a template attempts to call a method
+// which is not allowed by its sandbox.
+type SandboxError struct {
+	Type   reflect.Type
+	Method string
+}
+
+func (e *SandboxError) Error() string {
+	return fmt.Sprintf("method %s of %s is not allowed", e.Method, e.Type)
 }
 
 // The truth function will tell us if r is a truthy value or not. This is
@@ -119,8 +304,13 @@ out:
 	case reflect.Bool:
 		return r.Bool()
 	case reflect.Ptr, reflect.Interface:
+		if r.IsNil() {
+			return false
+		}
 		r = r.Elem()
 		goto out
+	case reflect.Invalid:
+		return false
 	default:
 		return r.Interface() != nil
 	}
@@ -114,3 +114,123 @@ func TestTruth(t *testing.T) {
 		}
 	}
 }
+
+type sandboxUser struct {
+	Name  string
+	Posts []string
+	Role  sandboxRole
+}
+
+type sandboxRole int
+
+func (r sandboxRole) String() string { return "admin" }
+
+func (u sandboxUser) Greeting() string { return "Hi " + u.Name }
+
+func (u sandboxUser) Delete() bool { return true }
+
+func (u sandboxUser) TemplateSafe() {}
+
+func TestSandbox(t *testing.T) {
+	type safe interface{ TemplateSafe() }
+	user := sandboxUser{Name: "ann", Posts: []string{"a"}}
+	for _, test := range []struct {
+		template string
+		options  []Option
+		expected string
+		err      bool
+	}{
+		{"{{Greeting}}", nil, "Hi ann", false},
+		{"{{Name}}{{#Posts}}{{.}}{{/Posts}}", []Option{Sandbox(nil)}, "anna", false},
+		{"{{Greeting}}", []Option{Sandbox(nil)}, "", true},
+		{"{{#Posts}}{{#Delete}}deleted{{/Delete}}{{/Posts}}", []Option{Sandbox(nil)}, "", true},
+		{"{{Greeting}}", []Option{Sandbox(AllowMethods("mustache.sandboxUser.Greeting"))}, "Hi ann", false},
+		{"{{Delete}}", []Option{Sandbox(AllowMethods("mustache.sandboxUser.Greeting"))}, "", true},
+		{"{{Delete}}", []Option{Sandbox(AllowInterface((*safe)(nil)))}, "true", false},
+		{"{{Role}}", nil, "admin", false},
+		{"{{Role}}", []Option{Sandbox(nil)}, "", true},
+		{"{{Role}}", []Option{Sandbox(AllowMethods("mustache.sandboxRole.String"))}, "admin", false},
+	} {
+		partial := New(Name("partial"))
+		if err := partial.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		template := New(append(test.options, Partial(partial))...)
+		if err := template.ParseString("{{>partial}}"); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(user)
+		if test.err {
+			if _, ok := err.(*SandboxError); !ok {
+				t.Errorf("%s: expected a sandbox error, got %v", test.template, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.template, err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.template, test.expected, output)
+		}
+	}
+}
+
+type lookupKey string
+
+func TestIndexLookup(t *testing.T) {
+	context := map[string]interface{}{
+		"items":  []map[string]string{{"name": "a"}, {"name": "b"}},
+		"matrix": [2][3]int{{1, 2, 3}, {4, 5, 6}},
+		"ids":    map[int]string{1: "one", -2: "minus two"},
+		"sizes":  map[uint8]string{8: "byte"},
+		"keys":   map[lookupKey]string{"k": "named"},
+	}
+	for _, test := range []struct {
+		template string
+		negative bool
+		expected string
+	}{
+		{"{{items.0.name}}{{items.1.name}}{{items.2.name}}", false, "ab"},
+		{"{{matrix.1.2}}{{matrix.0.0}}", false, "61"},
+		{"{{items.-1.name}}{{matrix.-1.-3}}", false, ""},
+		{"{{items.-1.name}}{{matrix.-1.-3}}{{items.-3.name}}", true, "b4"},
+		{"{{ids.1}} {{ids.-2}} {{ids.x}}{{sizes.8}}{{sizes.256}}{{keys.k}}", false, "one minus two bytenamed"},
+	} {
+		template := New(NegativeIndex(test.negative))
+		if err := template.ParseString(test.template); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(context)
+		if err != nil {
+			t.Error(err)
+		}
+		if output != test.expected {
+			t.Errorf("%s: expected %q got %q", test.template, test.expected, output)
+		}
+	}
+}
+
+func TestMapKeyLookup(t *testing.T) {
+	type point struct{ X, Y int }
+	for _, test := range []struct {
+		context interface{}
+		name    string
+		value   interface{}
+	}{
+		{map[lookupKey]string{"a": "named"}, "a", "named"},
+		{map[interface{}]interface{}{"a": map[interface{}]interface{}{1: "yaml"}}, "a.1", "yaml"},
+		{map[interface{}]interface{}{true: "yes", 2.5: "float"}, "true", "yes"},
+		{map[interface{}]string{"1": "string", 1: "int"}, "1", "string"},
+		{map[float64]string{2: "two"}, "2", "two"},
+		{map[bool]string{false: "no"}, "false", "no"},
+		{map[int]string{1: "one"}, "one", nil},
+		{map[uint8]string{1: "one"}, "-1", nil},
+		{map[point]string{{1, 2}: "point"}, "1", nil},
+		{map[reflect.Type]string{reflect.TypeOf(0): "int"}, "int", nil},
+	} {
+		value, _ := lookup(test.name, test.context)
+		if value != test.value {
+			t.Errorf("%s: expected %v, got %v", test.name, test.value, value)
+		}
+	}
+}
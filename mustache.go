@@ -9,6 +9,8 @@ import (
 	"io/ioutil"
 	"reflect"
 	"strings"
+
+	"github.com/alexkappa/mustache/ast"
 )
 
 // The node type is the base type that represents a node in the parse tree.
@@ -43,20 +45,34 @@ func (n textNode) String() string {
 // The varNode type represents a part of the template that needs to be replaced
 // by a variable that exists within c.
 type varNode struct {
-	name   string
-	escape bool
+	name    string
+	escape  bool
+	filters []ast.Filter
 }
 
 func (n *varNode) render(t *Template, w *writer, c ...interface{}) error {
 	w.text()
-	v, _ := lookup(n.name, c...)
+	v, _, err := t.lookup(n.name, c...)
+	if err != nil {
+		return err
+	}
+	// Filters apply to missing values as well, so that they may provide one.
+	if len(n.filters) > 0 {
+		if v, err = filter(t, v, n.filters); err != nil {
+			return err
+		}
+	}
 	// If the value is present but 'falsy', such as a false bool, or a zero int,
 	// we still want to render that value.
 	if v != nil {
-		if n.escape {
-			v = escape(fmt.Sprintf("%v", v))
+		s, err := t.format(v)
+		if err != nil {
+			return err
 		}
-		print(w, v)
+		if n.escape && t.escape == EscapeHTML {
+			s = escape(s)
+		}
+		io.WriteString(w, s)
 		return nil
 	}
 	return fmt.Errorf("failed to lookup %s", n.name)
@@ -77,27 +93,49 @@ type sectionNode struct {
 func (n *sectionNode) render(t *Template, w *writer, c ...interface{}) error {
 	w.tag()
 	defer w.tag()
-	elemFn := func(v ...interface{}) {
+	if t.catalog != nil && n.name == translateSection && !n.inverted {
+		return t.translate(n.elems, w, c...)
+	}
+	elemFn := func(v ...interface{}) error {
+		if !n.inverted {
+			w.enterSection()
+			defer w.leaveSection()
+		}
 		for _, elem := range n.elems {
-			elem.render(t, w, append(v, c...)...)
+			if err := elem.render(t, w, append(v, c...)...); fatal(err) {
+				return err
+			}
+			if err := w.limit.check(); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
+	v, ok, err := t.lookup(n.name, c...)
+	if err != nil {
+		return err
 	}
-	v, ok := lookup(n.name, c...)
 	if ok != n.inverted {
 		r := reflect.ValueOf(v)
 		switch r.Kind() {
 		case reflect.Slice, reflect.Array:
 			if r.Len() > 0 {
 				for i := 0; i < r.Len(); i++ {
-					elemFn(r.Index(i).Interface())
+					if err := w.limit.iterate(); err != nil {
+						return err
+					}
+					v := []interface{}{r.Index(i).Interface()}
+					if t.iteration {
+						v = append(v, newIteration(i, r.Len()))
+					}
+					if err := elemFn(v...); err != nil {
+						return err
+					}
 				}
-			} else {
-				elemFn(v)
+				return nil
 			}
-		default:
-			elemFn(v)
 		}
-		return nil
+		return elemFn(v)
 	}
 	return fmt.Errorf("failed to lookup %s", n.name)
 }
@@ -127,16 +165,44 @@ type partialNode struct {
 func (p *partialNode) render(t *Template, w *writer, c ...interface{}) error {
 	w.tag()
 	if template, ok := t.partials[p.name]; ok {
-		template.partials = t.partials
-		template.render(w, c...)
+		if err := w.limit.enter(); err != nil {
+			return err
+		}
+		defer w.limit.leave()
+		if err := w.enterPartial(p.name); err != nil {
+			return err
+		}
+		defer w.leavePartial()
+		// Partials are rendered with the options of the template including them,
+		// only their name and parse tree are their own. A copy is rendered so
+		// that templates may be rendered concurrently.
+		partial := *t
+		partial.name, partial.elems, partial.tree = template.name, template.elems, template.tree
+		if err := partial.render(w, c...); err != nil {
+			return err
+		}
 	}
-	return nil
+	return w.limit.check()
 }
 
 func (p *partialNode) String() string {
 	return fmt.Sprintf("[partial: %s]", p.name)
 }
 
+// maxPartialDepth is the depth of nested partials at which the rendering is
+// considered to recurse indefinitely, unless MaxDepth sets a lower limit.
+const maxPartialDepth = 10000
+
+// The RecursionError type is returned when a partial includes itself, directly
+// or through other partials, in a way that would recurse indefinitely.
+type RecursionError struct {
+	Partials []string // the cycle of partials, starting and ending with the same one
+}
+
+func (e *RecursionError) Error() string {
+	return "recursive partial " + strings.Join(e.Partials, " > ")
+}
+
 type delimNode string
 
 func (n delimNode) String() string {
@@ -148,6 +214,16 @@ func (n delimNode) render(t *Template, w *writer, c ...interface{}) error {
 	return nil
 }
 
+// The fatal function reports whether err stops the rendering even if misses
+// are silent, such as exceeding a limit or breaking out of the sandbox.
+func fatal(err error) bool {
+	switch err.(type) {
+	case *LimitError, *SandboxError, *RecursionError:
+		return true
+	}
+	return false
+}
+
 // The print function is able to format the interface v and write it to w using
 // the best possible formatting flags.
 func print(w io.Writer, v interface{}) {
@@ -213,7 +289,9 @@ func Delimiters(start, end string) Option {
 }
 
 // Partial sets p as a partial to the template. It is important to set the name
-// of p so that it may be looked up by the parent template.
+// of p so that it may be looked up by the parent template. Partials are
+// rendered with the options of the template including them, rather than their
+// own, so that the options of the rendered template apply throughout.
 func Partial(p *Template) Option {
 	return func(t *Template) {
 		t.partials[p.name] = p
@@ -237,14 +315,45 @@ func SilentMiss(silent bool) Option {
 	}
 }
 
+// The EscapeMode type describes how the values of {{name}} tags are escaped
+// when rendering. Values of {{{name}}} and {{&name}} tags are never escaped.
+type EscapeMode int
+
+const (
+	// EscapeHTML escapes values for HTML documents. This is the default.
+	EscapeHTML EscapeMode = iota
+	// EscapeNone doesn't escape values, for plain text output.
+	EscapeNone
+)
+
+// Escape sets how the values of {{name}} tags are escaped when rendering.
+func Escape(m EscapeMode) Option {
+	return func(t *Template) {
+		t.escape = m
+	}
+}
+
 // The Template type represents a template and its components.
 type Template struct {
-	name       string
-	elems      []node
-	partials   map[string]*Template
-	startDelim string
-	endDelim   string
-	silentMiss bool
+	name          string
+	elems         []node
+	tree          []ast.Node
+	partials      map[string]*Template
+	startDelim    string
+	endDelim      string
+	silentMiss    bool
+	escape        EscapeMode
+	limits        limits
+	methods       MethodPolicy
+	iteration     bool
+	negativeIndex bool
+	filters       map[string]Filter
+	formatter     FormatFunc
+	formatters    map[reflect.Type]FormatFunc
+	nilEmpty      bool
+	catalog       *Catalog
+	locale        string
+	catalogLocale string
 }
 
 // New returns a new Template instance.
@@ -275,12 +384,17 @@ func (t *Template) Parse(r io.Reader) error {
 		return err
 	}
 	l := newLexer(string(b), t.startDelim, t.endDelim)
+	l.filters = t.filters != nil
 	p := newParser(l)
-	elems, err := p.parse()
+	tree, err := p.parseTree()
 	if err != nil {
 		return err
 	}
-	t.elems = elems
+	if err := checkFilters(t, tree); err != nil {
+		return err
+	}
+	t.tree = tree
+	t.elems = compile(tree)
 	return nil
 }
 
@@ -297,8 +411,12 @@ func (t *Template) ParseBytes(b []byte) error {
 func (t *Template) render(w *writer, context ...interface{}) error {
 	for _, elem := range t.elems {
 		err := elem.render(t, w, context...)
+		// Exceeding a limit stops the rendering, even if misses are silent.
+		if err := w.limit.check(); err != nil {
+			return err
+		}
 		if err != nil {
-			if !t.silentMiss {
+			if !t.silentMiss || fatal(err) {
 				return err
 			}
 		}
@@ -309,7 +427,28 @@ func (t *Template) render(w *writer, context ...interface{}) error {
 // Render walks through the template's parse tree and writes the output to w
 // replacing the values found in context.
 func (t *Template) Render(w io.Writer, context ...interface{}) error {
-	return t.render(newWriter(w), context...)
+	writer := newWriter(w)
+	writer.limit = newLimiter(t)
+	return t.render(writer, context...)
+}
+
+// EscapeMode returns how the values of {{name}} tags are escaped when the
+// template is rendered.
+func (t *Template) EscapeMode() EscapeMode {
+	return t.escape
+}
+
+// lookup searches for name within the context chain, following the options of
+// the template.
+func (t *Template) lookup(name string, context ...interface{}) (interface{}, bool, error) {
+	o := lookupOptions{methods: t.methods, negativeIndex: t.negativeIndex}
+	return o.lookup(name, context...)
+}
+
+// Tree returns the parse tree of the template. The tree is shared with the
+// template and should not be modified.
+func (t *Template) Tree() *ast.Tree {
+	return &ast.Tree{Name: t.name, Nodes: t.tree}
 }
 
 // RenderString is a helper function that renders the template as a string.
@@ -51,18 +51,39 @@ func TestFalsyTemplate(t *testing.T) {
 	}
 }
 
+func TestEscape(t *testing.T) {
+	for mode, expected := range map[EscapeMode]string{
+		EscapeHTML: "&lt;b&gt; <b> &lt;b&gt;",
+		EscapeNone: "<b> <b> <b>",
+	} {
+		// Partials are escaped the same way as the template including them.
+		partial := New(Name("partial"))
+		if err := partial.ParseString("{{foo}}"); err != nil {
+			t.Fatal(err)
+		}
+		template := New(Escape(mode), Partial(partial))
+		if err := template.ParseString("{{foo}} {{{foo}}} {{>partial}}"); err != nil {
+			t.Fatal(err)
+		}
+		output, _ := template.RenderString(map[string]string{"foo": "<b>"})
+		if output != expected {
+			t.Errorf("expected %q got %q", expected, output)
+		}
+	}
+}
+
 func TestParseTree(t *testing.T) {
 	template := New()
 	template.elems = []node{
 		textNode("Lorem ipsum dolor sit "),
-		&varNode{"foo", false},
+		&varNode{"foo", false, nil},
 		textNode(", "),
 		&sectionNode{"bar", false, []node{
-			&varNode{"baz", true},
+			&varNode{"baz", true, nil},
 			textNode(" adipiscing"),
 		}},
 		textNode(" elit. Proin commodo viverra elit "),
-		&varNode{"zer", false},
+		&varNode{"zer", false, nil},
 		textNode("."),
 	}
 	data := map[string]interface{}{
@@ -87,3 +108,49 @@ func TestParseTree(t *testing.T) {
 		t.Log(b.String())
 	}
 }
+
+func TestRecursivePartials(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		context  interface{}
+		expected string
+		cycle    string
+	}{
+		{"a{{>b}}", "b{{>a}}", nil, "", "recursive partial a > b > a"},
+		{"a{{#x}}{{>b}}{{/x}}", "b{{>b}}", map[string]bool{"x": true}, "", "recursive partial b > b"},
+		{"a{{^x}}{{>b}}{{/x}}", "b{{>a}}", nil, "", "recursive partial a > b > a"},
+		{
+			"[{{#nodes}}{{>b}}{{/nodes}}]", "{{name}}{{>a}}",
+			map[string]interface{}{"nodes": []interface{}{
+				map[string]interface{}{"name": "x", "nodes": []interface{}{
+					map[string]interface{}{"name": "y", "nodes": false},
+				}},
+			}},
+			"[x[y[]]]", "",
+		},
+	} {
+		a, b := New(Name("a")), New(Name("b"))
+		if err := a.ParseString(test.a); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.ParseString(test.b); err != nil {
+			t.Fatal(err)
+		}
+		template := New(Partial(a), Partial(b))
+		if err := template.ParseString("{{>a}}"); err != nil {
+			t.Fatal(err)
+		}
+		output, err := template.RenderString(test.context)
+		if test.cycle != "" {
+			if _, ok := err.(*RecursionError); !ok || err.Error() != test.cycle {
+				t.Errorf("expected error %q, got %v", test.cycle, err)
+			}
+		} else if err != nil {
+			t.Error(err)
+		}
+		// Cycles are caught at the first repeat, before any output is flushed.
+		if output != test.expected {
+			t.Errorf("expected %q got %q", test.expected, output)
+		}
+	}
+}
@@ -5,6 +5,10 @@ package mustache
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+
+	"github.com/alexkappa/mustache/ast"
 )
 
 type parser struct {
@@ -56,24 +60,6 @@ func (p *parser) readt(t tokenType) ([]token, error) {
 	}
 }
 
-// readv returns the tokens starting from the current position until the first
-// match of t. A match is made only of t.typ and t.val are equal to the examined
-// token.
-func (p *parser) readv(t token) ([]token, error) {
-	var tokens []token
-	for {
-		read, err := p.readt(t.typ)
-		tokens = append(tokens, read...)
-		if err != nil {
-			return tokens, err
-		}
-		if len(read) > 0 && read[len(read)-1].val == t.val {
-			break
-		}
-	}
-	return tokens, nil
-}
-
 // peek returns the next token without advancing the cursor. Consecutive calls
 // of peek would result in the same token being retuned. To advance the cursor,
 // a read must be made.
@@ -124,93 +110,172 @@ func (p *parser) peekt(t tokenType) ([]token, error) {
 	}
 }
 
+// The ParseError type describes a syntax error found when parsing a template.
+type ParseError struct {
+	Line int    // line of the error, counted from 1
+	Col  int    // column of the error, counted from 1
+	Msg  string // description of the error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d syntax error: %s", e.Line, e.Col, e.Msg)
+}
+
 func (p *parser) errorf(t token, format string, v ...interface{}) error {
-	return fmt.Errorf("%d:%d syntax error: %s", t.line, t.col, fmt.Sprintf(format, v...))
+	return p.errorAt(pos(t), format, v...)
+}
+
+func (p *parser) errorAt(at ast.Pos, format string, v ...interface{}) error {
+	return &ParseError{at.Line, at.Col, fmt.Sprintf(format, v...)}
 }
 
-// parse begins parsing based on tokens read from the lexer.
+// parse begins parsing based on tokens read from the lexer and returns the
+// nodes used to render the template.
 func (p *parser) parse() ([]node, error) {
-	var nodes []node
-loop:
+	tree, err := p.parseTree()
+	return compile(tree), err
+}
+
+// parseTree begins parsing based on tokens read from the lexer and returns the
+// parse tree of the template.
+func (p *parser) parseTree() ([]ast.Node, error) {
+	return p.parseNodes(nil)
+}
+
+// parseNodes parses nodes until the end of the template or, if section is not
+// nil, until the tag closing section. Nested sections are parsed recursively,
+// so that the template is read in a single pass.
+func (p *parser) parseNodes(section *ast.Section) ([]ast.Node, error) {
+	var nodes []ast.Node
 	for {
 		token := p.read()
 		switch token.typ {
 		case tokenEOF:
-			break loop
+			if section != nil {
+				return nodes, p.errorAt(section.Start, "section %s opened at %s is never closed", section.Name, section.Start)
+			}
+			return nodes, nil
 		case tokenError:
 			return nil, p.errorf(token, "%s", token.val)
 		case tokenText:
-			nodes = append(nodes, textNode(token.val))
+			nodes = append(nodes, &ast.Text{Start: pos(token), Text: token.val})
 		case tokenLeftDelim:
-			node, err := p.parseTag()
+			if p.peek().typ == tokenSectionEnd {
+				p.read()
+				return nodes, p.parseSectionEnd(token, section)
+			}
+			node, err := p.parseTag(token)
 			if err != nil {
 				return nodes, err
 			}
 			nodes = append(nodes, node)
 		case tokenRawStart:
-			node, err := p.parseRawTag()
+			node, err := p.parseRawTag(token)
 			if err != nil {
 				return nodes, err
 			}
 			nodes = append(nodes, node)
 		case tokenSetDelim:
-			nodes = append(nodes, new(delimNode))
+			delim := &ast.Delim{Start: pos(token)}
+			if d := strings.Fields(token.val); len(d) == 2 {
+				delim.Left, delim.Right = d[0], d[1]
+			}
+			nodes = append(nodes, delim)
 		}
 	}
-	return nodes, nil
 }
 
 // parseTag parses a beggining of a mustache tag. It is assumed that a leftDelim
-// was already read by the parser.
-func (p *parser) parseTag() (node, error) {
+// was already read by the parser and is given as start.
+func (p *parser) parseTag(start token) (ast.Node, error) {
 	token := p.read()
 	switch token.typ {
 	case tokenIdentifier:
-		return p.parseVar(token, true)
+		return p.parseVar(start, token, true)
 	case tokenRawStart:
-		return p.parseRawTag()
+		return p.parseRawTag(start)
 	case tokenRawAlt:
-		return p.parseVar(p.read(), false)
+		return p.parseVar(start, p.read(), false)
 	case tokenComment:
-		return p.parseComment()
+		return p.parseComment(start)
 	case tokenSectionInverse:
-		return p.parseSection(true)
+		return p.parseSection(start, true)
 	case tokenSectionStart:
-		return p.parseSection(false)
+		return p.parseSection(start, false)
 	case tokenPartial:
-		return p.parsePartial()
+		return p.parsePartial(start)
 	}
 	return nil, p.errorf(token, "unreachable code %s", token)
 }
 
 // parseRawTag parses a simple variable tag. It is assumed that the read from
 // the parser should return an identifier.
-func (p *parser) parseRawTag() (node, error) {
+func (p *parser) parseRawTag(start token) (ast.Node, error) {
 	t := p.read()
 	if t.typ != tokenIdentifier {
 		return nil, p.errorf(t, "unexpected token %s", t)
 	}
+	filters, err := p.parseFilters()
+	if err != nil {
+		return nil, err
+	}
 	if next := p.read(); next.typ != tokenRawEnd {
 		return nil, p.errorf(t, "unexpected token %s", t)
 	}
 	if next := p.read(); next.typ != tokenRightDelim {
 		return nil, p.errorf(t, "unexpected token %s", t)
 	}
-	return &varNode{name: t.val, escape: false}, nil
+	return &ast.Var{Start: pos(start), Name: t.val, Escaped: false, Triple: true, Filters: filters}, nil
 }
 
 // parseVar parses a simple variable tag. It is assumed that the read from the
 // parser should return an identifier.
-func (p *parser) parseVar(ident token, escape bool) (node, error) {
+func (p *parser) parseVar(start, ident token, escape bool) (ast.Node, error) {
+	filters, err := p.parseFilters()
+	if err != nil {
+		return nil, err
+	}
 	if t := p.read(); t.typ != tokenRightDelim {
 		return nil, p.errorf(t, "unexpected token %s", t)
 	}
-	return &varNode{name: ident.val, escape: escape}, nil
+	return &ast.Var{Start: pos(start), Name: ident.val, Escaped: escape, Filters: filters}, nil
+}
+
+// parseFilters parses the filters following the name of a variable, such as
+// | truncate 20 | upper. The lexer only produces pipes when the filter
+// extension is enabled, so no filters are found otherwise.
+func (p *parser) parseFilters() ([]ast.Filter, error) {
+	var filters []ast.Filter
+	for p.peek().typ == tokenPipe {
+		p.read()
+		t := p.read()
+		if t.typ != tokenIdentifier {
+			return nil, p.errorf(t, "expected a filter name, found %s", t)
+		}
+		filter := ast.Filter{Name: t.val}
+	Args:
+		for {
+			switch t := p.peek(); t.typ {
+			case tokenIdentifier:
+				filter.Args = append(filter.Args, p.read().val)
+			case tokenString:
+				s, err := strconv.Unquote(p.read().val)
+				if err != nil {
+					return nil, p.errorf(t, "invalid quoted string %s", t.val)
+				}
+				filter.Args = append(filter.Args, s)
+			default:
+				break Args
+			}
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
 }
 
 // parseComment parses a comment block. It is assumed that the next read should
 // return a t_comment token.
-func (p *parser) parseComment() (node, error) {
+func (p *parser) parseComment(start token) (ast.Node, error) {
 	var comment string
 	for {
 		t := p.read()
@@ -220,7 +285,7 @@ func (p *parser) parseComment() (node, error) {
 		case tokenError:
 			return nil, p.errorf(t, t.val)
 		case tokenRightDelim:
-			return commentNode(comment), nil
+			return &ast.Comment{Start: pos(start), Text: comment}, nil
 		default:
 			comment += t.val
 		}
@@ -228,8 +293,8 @@ func (p *parser) parseComment() (node, error) {
 }
 
 // parseSection parses a section block. It is assumed that the next read should
-// return a t_section token.
-func (p *parser) parseSection(inverse bool) (node, error) {
+// return a t_ident token.
+func (p *parser) parseSection(start token, inverse bool) (ast.Node, error) {
 	t := p.read()
 	if t.typ != tokenIdentifier {
 		return nil, p.errorf(t, "unexpected token %s", t)
@@ -237,47 +302,39 @@ func (p *parser) parseSection(inverse bool) (node, error) {
 	if next := p.read(); next.typ != tokenRightDelim {
 		return nil, p.errorf(t, "unexpected token %s", t)
 	}
-	var (
-		tokens []token
-		stack  = 1
-	)
-	for {
-		read, err := p.readv(t)
-		if err != nil {
-			return nil, err
-		}
-		tokens = append(tokens, read...)
-		if len(read) > 1 {
-			// Check the token that preceeded the matching identifier. For
-			// section start and inverse tokens we increase the stack, otherwise
-			// decrease.
-			tt := read[len(read)-2]
-			switch {
-			case tt.typ == tokenSectionStart || tt.typ == tokenSectionInverse:
-				stack++
-			case tt.typ == tokenSectionEnd:
-				stack--
-			}
-		}
-		if stack == 0 {
-			break
-		}
-	}
-	nodes, err := subParser(tokens[:len(tokens)-3]).parse()
+	section := &ast.Section{Start: pos(start), Name: t.val, Inverted: inverse}
+	nodes, err := p.parseNodes(section)
 	if err != nil {
 		return nil, err
 	}
-	section := &sectionNode{
-		name:     t.val,
-		inverted: inverse,
-		elems:    nodes,
-	}
+	section.Nodes = nodes
 	return section, nil
 }
 
+// parseSectionEnd parses the tag closing section, which is nil at the top
+// level of the template. It is assumed that the next read should return a
+// t_ident token.
+func (p *parser) parseSectionEnd(start token, section *ast.Section) error {
+	t := p.read()
+	if t.typ != tokenIdentifier {
+		return p.errorf(t, "unexpected token %s", t)
+	}
+	if next := p.read(); next.typ != tokenRightDelim {
+		return p.errorf(t, "unexpected token %s", t)
+	}
+	if section == nil {
+		return p.errorf(start, "closing tag /%s at %s without an opening section", t.val, pos(start))
+	}
+	if t.val != section.Name {
+		return p.errorf(start, "section %s opened at %s closed by /%s at %s", section.Name, section.Start, t.val, pos(start))
+	}
+	section.End = pos(start)
+	return nil
+}
+
 // parsePartial parses a partial block. It is assumed that the next read should
 // return a t_ident token.
-func (p *parser) parsePartial() (node, error) {
+func (p *parser) parsePartial(start token) (ast.Node, error) {
 	t := p.read()
 	if t.typ != tokenIdentifier {
 		return nil, p.errorf(t, "unexpected token %s", t)
@@ -285,15 +342,37 @@ func (p *parser) parsePartial() (node, error) {
 	if next := p.read(); next.typ != tokenRightDelim {
 		return nil, p.errorf(t, "unexpected token %s", t)
 	}
-	return &partialNode{t.val}, nil
+	return &ast.Partial{Start: pos(start), Name: t.val}, nil
+}
+
+// pos returns the position of t in the template.
+func pos(t token) ast.Pos {
+	return ast.Pos{Line: t.line, Col: t.col}
+}
+
+// compile converts the parse tree nodes to the nodes used when rendering.
+func compile(tree []ast.Node) []node {
+	var nodes []node
+	for _, n := range tree {
+		switch n := n.(type) {
+		case *ast.Text:
+			nodes = append(nodes, textNode(n.Text))
+		case *ast.Var:
+			nodes = append(nodes, &varNode{n.Name, n.Escaped, n.Filters})
+		case *ast.Section:
+			nodes = append(nodes, &sectionNode{n.Name, n.Inverted, compile(n.Nodes)})
+		case *ast.Partial:
+			nodes = append(nodes, &partialNode{n.Name})
+		case *ast.Comment:
+			nodes = append(nodes, commentNode(n.Text))
+		case *ast.Delim:
+			nodes = append(nodes, new(delimNode))
+		}
+	}
+	return nodes
 }
 
 // newParser creates a new parser using the suppliad lexer.
 func newParser(l *lexer) *parser {
 	return &parser{lexer: l}
 }
-
-// subParser creates a new parser with a pre-defined token buffer.
-func subParser(b []token) *parser {
-	return &parser{buf: append(b, token{typ: tokenEOF})}
-}
@@ -3,8 +3,12 @@
 package mustache
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/alexkappa/mustache/ast"
 )
 
 func TestParser(t *testing.T) {
@@ -27,7 +31,7 @@ func TestParser(t *testing.T) {
 			"\nfoo {{bar}} {{#alex}}\r\n\tbaz\n{{/alex}} {{!foo}}",
 			[]node{
 				textNode("\nfoo "),
-				&varNode{"bar", true},
+				&varNode{"bar", true, nil},
 				textNode(" "),
 				&sectionNode{"alex", false, []node{
 					textNode("\r\n\tbaz\n"),
@@ -51,7 +55,7 @@ func TestParser(t *testing.T) {
 			[]node{
 				&sectionNode{"list", false, []node{
 					textNode("("),
-					&varNode{".", true},
+					&varNode{".", true, nil},
 					textNode(")"),
 				}},
 			},
@@ -69,3 +73,114 @@ func TestParser(t *testing.T) {
 		}
 	}
 }
+
+func TestTree(t *testing.T) {
+	template := New(Name("tree"))
+	err := template.ParseString("Hi {{name}}!\n{{#items}}\n  {{{.}}}{{>sep}}\n{{/items}}{{! done }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ast.Tree{Name: "tree", Nodes: []ast.Node{
+		&ast.Text{Start: ast.Pos{Line: 1, Col: 1}, Text: "Hi "},
+		&ast.Var{Start: ast.Pos{Line: 1, Col: 4}, Name: "name", Escaped: true},
+		&ast.Text{Start: ast.Pos{Line: 1, Col: 12}, Text: "!\n"},
+		&ast.Section{
+			Start: ast.Pos{Line: 2, Col: 1},
+			End:   ast.Pos{Line: 4, Col: 1},
+			Name:  "items",
+			Nodes: []ast.Node{
+				&ast.Text{Start: ast.Pos{Line: 2, Col: 11}, Text: "\n  "},
+				&ast.Var{Start: ast.Pos{Line: 3, Col: 3}, Name: ".", Escaped: false, Triple: true},
+				&ast.Partial{Start: ast.Pos{Line: 3, Col: 10}, Name: "sep"},
+				&ast.Text{Start: ast.Pos{Line: 3, Col: 18}, Text: "\n"},
+			},
+		},
+		&ast.Comment{Start: ast.Pos{Line: 4, Col: 11}, Text: " done "},
+	}}
+	if tree := template.Tree(); !reflect.DeepEqual(tree, expected) {
+		t.Errorf("unexpected tree %+v", tree.Nodes)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, test := range []struct {
+		template string
+		expected string
+	}{
+		{"{{#a}}{{#b}}{{/a}}{{/b}}", "1:13 syntax error: section b opened at 1:7 closed by /a at 1:13"},
+		{"\n\n  {{#a}}\n\n\n\n\n\n {{/b}}", "9:2 syntax error: section a opened at 3:3 closed by /b at 9:2"},
+		{"{{#a}}{{/a}}{{/b}}", "1:13 syntax error: closing tag /b at 1:13 without an opening section"},
+		{"{{#a}}\n  {{^b}}{{/b}}\n", "1:1 syntax error: section a opened at 1:1 is never closed"},
+		{"{{#a}}{{#b}}", "1:7 syntax error: section b opened at 1:7 is never closed"},
+		{"{{a-b}}", "1:5 syntax error: unexpected token t_error:\"unrecognized character in action: U+002D '-'\""},
+	} {
+		_, err := newParser(newLexer(test.template, "{{", "}}")).parse()
+		if err == nil {
+			t.Errorf("%q: expected an error", test.template)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("%q: expected error %q, got %q", test.template, test.expected, err)
+		}
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%q: expected a *ParseError, got %T", test.template, err)
+		}
+	}
+}
+
+// nestedTemplate returns a template of n sections nested in each other.
+func nestedTemplate(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "{{#s%d}}{{v}}\n", i)
+	}
+	for i := n - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "{{/s%d}}\n", i)
+	}
+	return b.String()
+}
+
+// longTemplate returns a template of n lines of text and tags.
+func longTemplate(n int) string {
+	return strings.Repeat("Hello {{name}}, {{#items}}{{.}} {{/items}}{{! note }}\n", n)
+}
+
+func TestParseNested(t *testing.T) {
+	template := New()
+	if err := template.ParseString(nestedTemplate(1000)); err != nil {
+		t.Fatal(err)
+	}
+	depth := 0
+	for nodes := template.Tree().Nodes; len(nodes) > 0; depth++ {
+		section := nodes[0].(*ast.Section)
+		if section.End.Line != 2000-depth {
+			t.Fatalf("section %s: expected to end on line %d, got %s", section.Name, 2000-depth, section.End)
+		}
+		nodes = section.Nodes[2:]
+	}
+	if depth != 1000 {
+		t.Errorf("expected depth 1000, got %d", depth)
+	}
+}
+
+func benchmarkParse(b *testing.B, template func(int) string) {
+	for _, n := range []int{100, 1000, 10000} {
+		s := template(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.SetBytes(int64(len(s)))
+			for i := 0; i < b.N; i++ {
+				if err := New().ParseString(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseNested(b *testing.B) {
+	benchmarkParse(b, nestedTemplate)
+}
+
+func BenchmarkParseLong(b *testing.B) {
+	benchmarkParse(b, longTemplate)
+}
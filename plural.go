@@ -0,0 +1,185 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The pluralOperands type holds the operands of a number used by the plural
+// rules of the Unicode CLDR.
+type pluralOperands struct {
+	n float64 // absolute value
+	i int64   // integer digits
+	v int     // number of visible fraction digits
+}
+
+func newPluralOperands(f float64) pluralOperands {
+	o := pluralOperands{n: math.Abs(f)}
+	s := strconv.FormatFloat(o.n, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		o.v = len(s) - i - 1
+		s = s[:i]
+	}
+	o.i, _ = strconv.ParseInt(s, 10, 64)
+	return o
+}
+
+// in reports whether x is within the range min..max.
+func in(x, min, max int64) bool {
+	return x >= min && x <= max
+}
+
+// The pluralRule type selects the plural category of numbers in a language,
+// following the cardinal rules of the Unicode CLDR.
+type pluralRule struct {
+	// forms are the categories of the translations of gettext plural entries,
+	// in order. If other isn't one of them, the last translation is used for
+	// it as well.
+	forms    []string
+	category func(o pluralOperands) string
+}
+
+var (
+	pluralOneOther = pluralRule{[]string{"one", "other"}, func(o pluralOperands) string {
+		if o.i == 1 && o.v == 0 {
+			return "one"
+		}
+		return "other"
+	}}
+	pluralOther = pluralRule{[]string{"other"}, func(o pluralOperands) string {
+		return "other"
+	}}
+	// pluralEastSlavic is the rule of Russian and Ukrainian.
+	pluralEastSlavic = pluralRule{[]string{"one", "few", "many"}, func(o pluralOperands) string {
+		switch {
+		case o.v != 0:
+			return "other"
+		case o.i%10 == 1 && o.i%100 != 11:
+			return "one"
+		case in(o.i%10, 2, 4) && !in(o.i%100, 12, 14):
+			return "few"
+		}
+		return "many"
+	}}
+	// pluralWestSlavic is the rule of Czech and Slovak.
+	pluralWestSlavic = pluralRule{[]string{"one", "few", "other"}, func(o pluralOperands) string {
+		switch {
+		case o.v != 0:
+			return "many"
+		case o.i == 1:
+			return "one"
+		case in(o.i, 2, 4):
+			return "few"
+		}
+		return "other"
+	}}
+)
+
+// pluralMillions returns a rule whose category of numbers which are a multiple
+// of a million is many, as in French, Italian, Portuguese and Spanish, and
+// whose category of other numbers is the one returned by one or else other.
+func pluralMillions(one func(o pluralOperands) bool) pluralRule {
+	return pluralRule{[]string{"one", "other"}, func(o pluralOperands) string {
+		switch {
+		case one(o):
+			return "one"
+		case o.v == 0 && o.i != 0 && o.i%1000000 == 0:
+			return "many"
+		}
+		return "other"
+	}}
+}
+
+// pluralRules are the plural rules of languages, or of locales whose rule
+// differs from the one of their language.
+var pluralRules = map[string]pluralRule{
+	"en":    pluralOneOther,
+	"de":    pluralOneOther,
+	"nl":    pluralOneOther,
+	"sv":    pluralOneOther,
+	"da":    pluralOneOther,
+	"nb":    pluralOneOther,
+	"fi":    pluralOneOther,
+	"el":    pluralOneOther,
+	"ja":    pluralOther,
+	"ko":    pluralOther,
+	"zh":    pluralOther,
+	"es":    pluralMillions(func(o pluralOperands) bool { return o.n == 1 }),
+	"it":    pluralMillions(func(o pluralOperands) bool { return o.i == 1 && o.v == 0 }),
+	"fr":    pluralMillions(func(o pluralOperands) bool { return o.i == 0 || o.i == 1 }),
+	"pt":    pluralMillions(func(o pluralOperands) bool { return o.i == 0 || o.i == 1 }),
+	"pt-pt": pluralMillions(func(o pluralOperands) bool { return o.i == 1 && o.v == 0 }),
+	"ru":    pluralEastSlavic,
+	"uk":    pluralEastSlavic,
+	"cs":    pluralWestSlavic,
+	"sk":    pluralWestSlavic,
+	"pl": {[]string{"one", "few", "many"}, func(o pluralOperands) string {
+		switch {
+		case o.v != 0:
+			return "other"
+		case o.i == 1:
+			return "one"
+		case in(o.i%10, 2, 4) && !in(o.i%100, 12, 14):
+			return "few"
+		}
+		return "many"
+	}},
+	"he": {[]string{"one", "two", "other"}, func(o pluralOperands) string {
+		switch {
+		case o.i == 1 && o.v == 0, o.i == 0 && o.v != 0:
+			return "one"
+		case o.i == 2 && o.v == 0:
+			return "two"
+		}
+		return "other"
+	}},
+	"ar": {[]string{"zero", "one", "two", "few", "many", "other"}, func(o pluralOperands) string {
+		if o.v != 0 {
+			return "other"
+		}
+		switch {
+		case o.i == 0:
+			return "zero"
+		case o.i == 1:
+			return "one"
+		case o.i == 2:
+			return "two"
+		case in(o.i%100, 3, 10):
+			return "few"
+		case in(o.i%100, 11, 99):
+			return "many"
+		}
+		return "other"
+	}},
+}
+
+// pluralCategories are the plural categories of the Unicode CLDR.
+var pluralCategories = map[string]bool{
+	"zero":  true,
+	"one":   true,
+	"two":   true,
+	"few":   true,
+	"many":  true,
+	"other": true,
+}
+
+// pluralRuleOf returns the plural rule of locale. Languages without a rule of
+// their own use the rule of English.
+func pluralRuleOf(locale string) pluralRule {
+	locale = strings.ToLower(strings.Replace(locale, "_", "-", -1))
+	if rule, ok := pluralRules[locale]; ok {
+		return rule
+	}
+	if rule, ok := pluralRules[language(locale)]; ok {
+		return rule
+	}
+	return pluralOneOther
+}
+
+// pluralCategory returns the plural category of n in the language of locale.
+func pluralCategory(locale string, n float64) string {
+	return pluralRuleOf(locale).category(newPluralOperands(n))
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+// schemaDraft is the JSON Schema dialect produced by JSONSchema.
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema infers a JSON Schema (draft 2020-12) describing the data expected
+// by the template. Variables become strings, numbers or booleans, sections
+// become booleans, objects or arrays depending on the names used inside them,
+// and dotted names become nested objects. Names used only by inverted sections
+// are optional, while every other name is required.
+//
+// Names used inside a section are attributed to the section, although they
+// may be resolved by an enclosing context when rendering. Registered partials
+// are followed as if they were part of the template.
+func (t *Template) JSONSchema() ([]byte, error) {
+	root := newSchemaObject()
+	s := &schemaBuilder{partials: t.partials, active: make(map[string]bool)}
+	s.nodes(t.tree, root, nil, true)
+	schema := root.schema()
+	schema["$schema"] = schemaDraft
+	if t.name != "" {
+		schema["title"] = t.name
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// The schemaField type accumulates the ways a name is used by a template.
+type schemaField struct {
+	scalar   bool          // used as a variable
+	section  bool          // used as a section
+	list     bool          // a section using "." inside it
+	required bool          // used by anything other than an inverted section
+	object   *schemaObject // names used by dotted names or inside a section
+}
+
+// The schemaObject type holds the fields of an object.
+type schemaObject struct {
+	fields map[string]*schemaField
+}
+
+func newSchemaObject() *schemaObject {
+	return &schemaObject{fields: make(map[string]*schemaField)}
+}
+
+// field returns the field of o named by name, following dotted names through
+// nested objects.
+func (o *schemaObject) field(name string, required bool) *schemaField {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		f, ok := o.fields[part]
+		if !ok {
+			f = &schemaField{}
+			o.fields[part] = f
+		}
+		f.required = f.required || required
+		if i == len(parts)-1 {
+			return f
+		}
+		if f.object == nil {
+			f.object = newSchemaObject()
+		}
+		o = f.object
+	}
+	return nil
+}
+
+func (o *schemaObject) schema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for name, f := range o.fields {
+		properties[name] = f.schema()
+		if f.required {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (f *schemaField) schema() map[string]interface{} {
+	scalar := map[string]interface{}{"type": []string{"string", "number", "boolean"}}
+	var any []interface{}
+	if f.scalar {
+		any = append(any, scalar)
+	}
+	hasFields := f.object != nil && len(f.object.fields) > 0
+	if f.section && !hasFields && !f.list {
+		any = append(any, map[string]interface{}{"type": "boolean"})
+	}
+	if hasFields {
+		object := f.object.schema()
+		any = append(any, object)
+		if f.section {
+			any = append(any, map[string]interface{}{"type": "array", "items": object})
+		}
+	}
+	if f.list {
+		any = append(any, map[string]interface{}{"type": "array", "items": scalar})
+	}
+	if len(any) == 1 {
+		return any[0].(map[string]interface{})
+	}
+	return map[string]interface{}{"anyOf": any}
+}
+
+// The schemaBuilder type holds the state of a JSONSchema inference.
+type schemaBuilder struct {
+	partials map[string]*Template
+	active   map[string]bool // partials being followed, to avoid recursion.
+}
+
+// nodes records the names used by nodes as fields of o. The field of the
+// enclosing section, if any, is given as current. Names used inside inverted
+// sections are recorded as optional fields of the enclosing object, since the
+// section is only rendered when its own value is missing or falsy.
+func (s *schemaBuilder) nodes(nodes []ast.Node, o *schemaObject, current *schemaField, required bool) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Var:
+			if n.Name == "." {
+				if current != nil {
+					current.list = true
+				}
+				continue
+			}
+			o.field(n.Name, required).scalar = true
+		case *ast.Section:
+			if n.Name == "." {
+				s.nodes(n.Nodes, o, current, required)
+				continue
+			}
+			f := o.field(n.Name, required && !n.Inverted)
+			f.section = true
+			if n.Inverted {
+				s.nodes(n.Nodes, o, current, false)
+				continue
+			}
+			if f.object == nil {
+				f.object = newSchemaObject()
+			}
+			s.nodes(n.Nodes, f.object, f, required)
+		case *ast.Partial:
+			p, ok := s.partials[n.Name]
+			if !ok || s.active[n.Name] {
+				continue
+			}
+			s.active[n.Name] = true
+			s.nodes(p.tree, o, current, required)
+			s.active[n.Name] = false
+		}
+	}
+}
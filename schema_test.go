@@ -0,0 +1,65 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	template := New(Name("invoice"))
+	err := template.ParseString(`{{customer.name}}
+{{#items}}{{title}}: {{price}}{{#tags}}{{.}}{{/tags}}{{/items}}
+{{#paid}}PAID{{/paid}}{{^notes}}{{fallback}}{{/notes}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := template.JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scalar := map[string]interface{}{"type": []interface{}{"string", "number", "boolean"}}
+	item := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title": scalar,
+			"price": scalar,
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": scalar,
+			},
+		},
+		"required": []interface{}{"price", "tags", "title"},
+	}
+	expected := map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "invoice",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"customer": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"name": scalar},
+				"required":   []interface{}{"name"},
+			},
+			"items": map[string]interface{}{
+				"anyOf": []interface{}{
+					item,
+					map[string]interface{}{"type": "array", "items": item},
+				},
+			},
+			"paid":     map[string]interface{}{"type": "boolean"},
+			"notes":    map[string]interface{}{"type": "boolean"},
+			"fallback": scalar,
+		},
+		"required": []interface{}{"customer", "items", "paid"},
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("unexpected schema %s", b)
+	}
+}
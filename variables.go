@@ -0,0 +1,89 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"strings"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+// The RefKind type identifies the kind of tag a name is referenced by.
+type RefKind int
+
+const (
+	VarRef     RefKind = iota // {{name}}, {{{name}}} or {{&name}}
+	SectionRef                // {{#name}} or {{^name}}
+	PartialRef                // {{>name}}
+)
+
+var refKindName = map[RefKind]string{
+	VarRef:     "variable",
+	SectionRef: "section",
+	PartialRef: "partial",
+}
+
+// String satisfies the fmt.Stringer interface.
+func (k RefKind) String() string {
+	return refKindName[k]
+}
+
+// The Ref type describes a reference to a name made by a template.
+type Ref struct {
+	Name     string   // the referenced name as it appears in the tag
+	Kind     RefKind  // kind of tag making the reference
+	Pos      ast.Pos  // position of the tag in the template
+	Path     []string // names of the enclosing sections, outermost first
+	Escaped  bool     // the variable is HTML escaped
+	Inverted bool     // the section is inverted
+	Dotted   bool     // the name uses the dot notation, such as {{a.b}}
+}
+
+// Variables returns every variable, section and partial referenced by the
+// template, in the order they appear. Partials are not followed.
+func (t *Template) Variables() []Ref {
+	return refs(t.tree, nil)
+}
+
+func refs(nodes []ast.Node, path []string) []Ref {
+	var r []Ref
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.Var:
+			r = append(r, Ref{
+				Name:    n.Name,
+				Kind:    VarRef,
+				Pos:     n.Start,
+				Path:    path,
+				Escaped: n.Escaped,
+				Dotted:  dotted(n.Name),
+			})
+		case *ast.Section:
+			r = append(r, Ref{
+				Name:     n.Name,
+				Kind:     SectionRef,
+				Pos:      n.Start,
+				Path:     path,
+				Inverted: n.Inverted,
+				Dotted:   dotted(n.Name),
+			})
+			// Copy the path so that references of sibling sections don't
+			// share the same backing array.
+			inner := append(append([]string(nil), path...), n.Name)
+			r = append(r, refs(n.Nodes, inner)...)
+		case *ast.Partial:
+			r = append(r, Ref{
+				Name: n.Name,
+				Kind: PartialRef,
+				Pos:  n.Start,
+				Path: path,
+			})
+		}
+	}
+	return r
+}
+
+// dotted reports whether name uses the dot notation.
+func dotted(name string) bool {
+	return name != "." && strings.Contains(name, ".")
+}
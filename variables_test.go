@@ -0,0 +1,35 @@
+// Copyright (c) 2014 Alex Kalyvitis
+
+package mustache
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexkappa/mustache/ast"
+)
+
+func TestVariables(t *testing.T) {
+	template := New()
+	err := template.ParseString("{{title}}\n{{#items}}{{{name}}} {{user.email}}{{^tags}}{{>empty}}{{/tags}}{{/items}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Ref{
+		{Name: "title", Kind: VarRef, Pos: ast.Pos{Line: 1, Col: 1}, Escaped: true},
+		{Name: "items", Kind: SectionRef, Pos: ast.Pos{Line: 2, Col: 1}},
+		{Name: "name", Kind: VarRef, Pos: ast.Pos{Line: 2, Col: 11}, Path: []string{"items"}},
+		{Name: "user.email", Kind: VarRef, Pos: ast.Pos{Line: 2, Col: 22}, Path: []string{"items"}, Escaped: true, Dotted: true},
+		{Name: "tags", Kind: SectionRef, Pos: ast.Pos{Line: 2, Col: 36}, Path: []string{"items"}, Inverted: true},
+		{Name: "empty", Kind: PartialRef, Pos: ast.Pos{Line: 2, Col: 45}, Path: []string{"items", "tags"}},
+	}
+	refs := template.Variables()
+	if len(refs) != len(expected) {
+		t.Fatalf("unexpected number of references %d, expected %d", len(refs), len(expected))
+	}
+	for i, ref := range refs {
+		if !reflect.DeepEqual(ref, expected[i]) {
+			t.Errorf("unexpected reference %+v, expected %+v", ref, expected[i])
+		}
+	}
+}
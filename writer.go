@@ -4,13 +4,24 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"unicode/utf8"
 )
 
 type writer struct {
-	hasText bool
-	hasTag  bool
-	w       io.Writer
-	b       *bufio.Writer
+	hasText  bool
+	hasTag   bool
+	w        io.Writer
+	b        *bufio.Writer
+	limit    *limiter
+	partials []activePartial // the partials being rendered
+	sections int             // the number of values pushed by sections
+}
+
+// The activePartial type is a partial being rendered, along with the number of
+// values pushed by sections when it was included.
+type activePartial struct {
+	name     string
+	sections int
 }
 
 func newWriter(w io.Writer) *writer {
@@ -30,6 +41,48 @@ func (w *writer) tag() {
 	w.hasTag = true
 }
 
+// enterPartial records that the partial named name is rendered. Including a
+// partial which is already being rendered, without a section pushing a value
+// onto the context chain in between, makes no progress, so it fails with a
+// *RecursionError. Inverted sections don't count, since their value is falsy
+// and can't lead to a different branch. The same goes for partials nested
+// too deeply.
+func (w *writer) enterPartial(name string) error {
+	for i := len(w.partials) - 1; i >= 0; i-- {
+		p := w.partials[i]
+		if p.name != name {
+			continue
+		}
+		if p.sections == w.sections || len(w.partials) >= maxPartialDepth {
+			cycle := make([]string, 0, len(w.partials)-i+1)
+			for _, p := range w.partials[i:] {
+				cycle = append(cycle, p.name)
+			}
+			return &RecursionError{Partials: append(cycle, name)}
+		}
+		break
+	}
+	w.partials = append(w.partials, activePartial{name, w.sections})
+	return nil
+}
+
+// enterSection records that a section pushed a value onto the context chain,
+// until leaveSection is called.
+func (w *writer) enterSection() {
+	w.sections++
+}
+
+// leaveSection records that the value pushed by the last section entered is
+// popped off the context chain.
+func (w *writer) leaveSection() {
+	w.sections--
+}
+
+// leavePartial records that the last partial entered is rendered.
+func (w *writer) leavePartial() {
+	w.partials = w.partials[:len(w.partials)-1]
+}
+
 func (w *writer) reset() {
 	w.hasTag = false
 	w.hasText = false
@@ -45,6 +98,9 @@ func (w *writer) flush() error {
 }
 
 func (w *writer) write(r rune) error {
+	if err := w.limit.write(utf8.RuneLen(r)); err != nil {
+		return err
+	}
 	_, err := w.b.WriteRune(r)
 	if err != nil {
 		return err